@@ -0,0 +1,174 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 通知のボタンの action_id
+const (
+	actionMuteThread  = "mute_thread"
+	actionSnooze      = "snooze"
+	actionUnsubscribe = "unsubscribe"
+	actionSeen        = "seen"
+)
+
+// snoozeDuration 「Snooze 2h」で通知を止める時間
+const snoozeDuration = "2h"
+
+// Message Slackへ送るメッセージ
+// Blocks がある場合も通知やWebhookのために Text は必ず入れる
+type Message struct {
+	Channel         string  `json:"channel,omitempty"`
+	Text            string  `json:"text"`
+	Blocks          []Block `json:"blocks,omitempty"`
+	ReplaceOriginal bool    `json:"replace_original,omitempty"`
+	ResponseType    string  `json:"response_type,omitempty"`
+}
+
+// Block Block Kitのブロック
+// Elements は actions ブロックではボタン、context ブロックでは TextObject
+// Label と Element は input ブロックで使う
+type Block struct {
+	Type     string        `json:"type"`
+	BlockID  string        `json:"block_id,omitempty"`
+	Text     *TextObject   `json:"text,omitempty"`
+	Elements []interface{} `json:"elements,omitempty"`
+	Label    *TextObject   `json:"label,omitempty"`
+	Element  interface{}   `json:"element,omitempty"`
+}
+
+// TextObject Block Kitのテキスト
+type TextObject struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Button Block Kitのボタン
+type Button struct {
+	Type     string     `json:"type"`
+	Text     TextObject `json:"text"`
+	ActionID string     `json:"action_id"`
+	Value    string     `json:"value,omitempty"`
+}
+
+// PlainTextInput Block Kitのテキスト入力
+type PlainTextInput struct {
+	Type      string `json:"type"`
+	ActionID  string `json:"action_id"`
+	Multiline bool   `json:"multiline,omitempty"`
+}
+
+// View Block Kitのモーダル
+type View struct {
+	Type            string     `json:"type"`
+	CallbackID      string     `json:"callback_id"`
+	PrivateMetadata string     `json:"private_metadata,omitempty"`
+	Title           TextObject `json:"title"`
+	Submit          TextObject `json:"submit"`
+	Close           TextObject `json:"close"`
+	Blocks          []Block    `json:"blocks"`
+}
+
+// newButton ボタンを生成する
+func newButton(text string, actionID string, value string) Button {
+	return Button{
+		Type:     "button",
+		Text:     TextObject{Type: "plain_text", Text: text},
+		ActionID: actionID,
+		Value:    value,
+	}
+}
+
+// sectionBlocks テキストをセクションブロックの上限で区切って並べる
+// 途中で切れたコードブロックは閉じて、次のセクションで開き直す
+func sectionBlocks(text string) []Block {
+	blocks := []Block{}
+	for text != "" {
+		chunk := cutText(text, slackSectionLimit-len("\n```"))
+		if chunk == "" {
+			chunk = string([]rune(text)[:slackSectionLimit-len("\n```")])
+		}
+		text = strings.TrimLeft(text[len(chunk):], "\n")
+		if closed := closeCodeFence(chunk); closed != chunk {
+			chunk = closed
+			if text != "" {
+				text = "```\n" + text
+			}
+		}
+		blocks = append(blocks, Block{Type: "section", Text: &TextObject{Type: "mrkdwn", Text: chunk}})
+	}
+	return blocks
+}
+
+// NewNotification 通知のテキストに操作用のボタンを付けたメッセージを生成する
+// ボタンの操作は署名を検証して受け付けるので、署名シークレットがなければテキストだけにする
+func NewNotification(text string, summary EventSummary, conf Config) Message {
+	msg := Message{Text: text}
+	if conf.SlackSigningSecret == "" {
+		return msg
+	}
+	buttons := []interface{}{}
+	if thread := summary.ThreadKey(); thread != "" {
+		buttons = append(buttons, newButton("Mute this thread", actionMuteThread, thread))
+	}
+	buttons = append(buttons, newButton("Snooze "+snoozeDuration, actionSnooze, snoozeDuration))
+	if keys := summary.repositoryKeys(); len(keys) > 0 {
+		buttons = append(buttons, newButton("Unsubscribe from repo", actionUnsubscribe, keys[0]))
+	}
+	buttons = append(buttons, newButton("Mark as seen", actionSeen, ""))
+	// 返信のショートカットで返信先が分かるよう block_id に埋め込んでおく
+	blockID := "gosla_notification"
+	if source, ok := ReplySourceOf(summary); ok {
+		blockID = source.String()
+	}
+	msg.Blocks = append(sectionBlocks(text), Block{Type: "actions", BlockID: blockID, Elements: buttons})
+	return msg
+}
+
+// NewConfirmation 元のメッセージからボタンを外し、操作の結果を添えたメッセージを生成する
+// response_url へ送って元のメッセージを置き換える
+// 置き換えた後も返信できるよう、返信先は context ブロックに引き継ぐ
+// 操作の結果は押した本人だけのものなので、DM以外では元のメッセージを残して本人にだけ見せる
+func NewConfirmation(in Interaction, confirmation string) Message {
+	if !in.InDirectMessage() {
+		return Message{Text: "✓ " + confirmation, ResponseType: "ephemeral"}
+	}
+	blockID := ""
+	if source, ok := in.ReplySource(); ok {
+		blockID = source.String()
+	}
+	blocks := append(sectionBlocks(in.Message.Text), Block{
+		Type:     "context",
+		BlockID:  blockID,
+		Elements: []interface{}{TextObject{Type: "mrkdwn", Text: "✓ " + confirmation}},
+	})
+	return Message{Text: in.Message.Text, Blocks: blocks, ReplaceOriginal: true}
+}
+
+// NewReplyModal Githubへ返信するためのモーダルを生成する
+// 返信先は private_metadata に入れて送信時に受け取る
+func NewReplyModal(source ReplySource) View {
+	return View{
+		Type:            "modal",
+		CallbackID:      ReplyCallbackID,
+		PrivateMetadata: source.String(),
+		Title:           TextObject{Type: "plain_text", Text: "Reply on GitHub"},
+		Submit:          TextObject{Type: "plain_text", Text: "Reply"},
+		Close:           TextObject{Type: "plain_text", Text: "Cancel"},
+		Blocks: []Block{
+			Block{
+				Type: "context",
+				Elements: []interface{}{
+					TextObject{Type: "mrkdwn", Text: mrkdwnEscaper.Replace(fmt.Sprintf("%v#%v", source.Repository, source.Number))},
+				},
+			},
+			Block{
+				Type:    "input",
+				BlockID: replyInputBlockID,
+				Label:   &TextObject{Type: "plain_text", Text: "Reply"},
+				Element: PlainTextInput{Type: "plain_text_input", ActionID: replyInputActionID, Multiline: true},
+			},
+		},
+	}
+}
@@ -0,0 +1,102 @@
+package lib
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSectionBlocks(t *testing.T) {
+	text := strings.Repeat("line\n", 500) + "```\n" + strings.Repeat("code\n", 500) + "```"
+	blocks := sectionBlocks(text)
+	if len(blocks) != 2 {
+		t.Fatal("failed: split", len(blocks))
+	}
+	for _, block := range blocks {
+		if len([]rune(block.Text.Text)) > slackSectionLimit {
+			t.Fatal("failed: section limit", len([]rune(block.Text.Text)))
+		}
+		if closeCodeFence(block.Text.Text) != block.Text.Text {
+			t.Fatal("failed: close code fence", block.Text.Text)
+		}
+	}
+	if !strings.HasPrefix(blocks[1].Text.Text, "```\ncode") {
+		t.Fatal("failed: reopen code fence", blocks[1].Text.Text[:10])
+	}
+	if len(sectionBlocks("")) != 0 {
+		t.Fatal("failed: empty text")
+	}
+}
+
+func TestNewNotification(t *testing.T) {
+	summary := EventSummary{Event: "discussion", RepositoryName: "repo", RepositoryFullName: "org/repo", Number: 12}
+	if msg := NewNotification("text", summary, Config{}); msg.Blocks != nil {
+		t.Fatal("failed: no buttons without signing secret", msg)
+	}
+
+	msg := NewNotification("text", summary, Config{SlackSigningSecret: "secret"})
+	if msg.Text != "text" || len(msg.Blocks) != 2 || msg.Blocks[0].Text.Text != "text" {
+		t.Fatal("failed: blocks", msg)
+	}
+	if msg.Blocks[1].BlockID != "gosla_notification" {
+		t.Fatal("failed: no reply source", msg.Blocks[1].BlockID)
+	}
+	summary.Event = "issue_comment"
+	msg = NewNotification("text", summary, Config{SlackSigningSecret: "secret"})
+	if msg.Blocks[1].BlockID != "gosla_reply:org/repo:12:0" {
+		t.Fatal("failed: reply source", msg.Blocks[1].BlockID)
+	}
+	b, _ := json.Marshal(msg.Blocks[1])
+	for _, s := range []string{`"action_id":"mute_thread","value":"org/repo#12"`, `"action_id":"snooze","value":"2h"`, `"action_id":"unsubscribe","value":"org/repo"`, `"action_id":"seen"`} {
+		if !strings.Contains(string(b), s) {
+			t.Fatal("failed: button", s, string(b))
+		}
+	}
+
+	// Issueやプルリクエスト以外にはスレッドのミュートを付けない
+	msg = NewNotification("text", EventSummary{Event: "workflow_run", RepositoryName: "repo", Number: 12}, Config{SlackSigningSecret: "secret"})
+	if b, _ := json.Marshal(msg.Blocks[1]); strings.Contains(string(b), "mute_thread") {
+		t.Fatal("failed: no thread", string(b))
+	}
+}
+
+func TestNewConfirmation(t *testing.T) {
+	in := Interaction{}
+	in.Channel.ID = "D1"
+	in.Message.Text = "text"
+	msg := NewConfirmation(in, "Muted org/repo#12")
+	b, _ := json.Marshal(msg)
+	if string(b) != `{"text":"text","blocks":[{"type":"section","text":{"type":"mrkdwn","text":"text"}},{"type":"context","elements":[{"type":"mrkdwn","text":"✓ Muted org/repo#12"}]}],"replace_original":true}` {
+		t.Fatal("failed: confirmation", string(b))
+	}
+
+	// 返信先は置き換えた後も残す
+	in.APIAppID = "A1"
+	in.Message.BotID = "B1"
+	in.Message.AppID = "A1"
+	in.Message.Blocks = append(in.Message.Blocks, struct {
+		BlockID string `json:"block_id"`
+	}{BlockID: "gosla_reply:org/repo:12:0"})
+	msg = NewConfirmation(in, "Marked as seen")
+	if msg.Blocks[len(msg.Blocks)-1].BlockID != "gosla_reply:org/repo:12:0" {
+		t.Fatal("failed: keep reply source", msg.Blocks)
+	}
+
+	// チャンネルのメッセージは置き換えず、押した本人にだけ結果を見せる
+	in.Channel.ID = "C1"
+	msg = NewConfirmation(in, "Snoozed notifications for 2h")
+	if msg.ReplaceOriginal || msg.ResponseType != "ephemeral" || msg.Text != "✓ Snoozed notifications for 2h" || msg.Blocks != nil {
+		t.Fatal("failed: confirmation in channel", msg)
+	}
+}
+
+func TestNewReplyModal(t *testing.T) {
+	view := NewReplyModal(ReplySource{Repository: "org/repo", Number: 12, ReviewThreadID: 34})
+	if view.CallbackID != ReplyCallbackID || view.PrivateMetadata != "gosla_reply:org/repo:12:34" {
+		t.Fatal("failed: modal", view)
+	}
+	b, _ := json.Marshal(view.Blocks[1])
+	if string(b) != `{"type":"input","block_id":"reply","label":{"type":"plain_text","text":"Reply"},"element":{"type":"plain_text_input","action_id":"body","multiline":true}}` {
+		t.Fatal("failed: input block", string(b))
+	}
+}
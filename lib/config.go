@@ -1,19 +1,196 @@
 package lib
 
 import (
+	"errors"
+	"fmt"
+	"path"
+
 	"github.com/BurntSushi/toml"
 )
 
 // Config GithubとSlackの連携情報を格納する構造体
 type Config struct {
-	Secret   string             `toml:"secret"`
-	Accounts map[string]Account `toml:"accounts"`
+	Secret        string                    `toml:"secret"`
+	Accounts      map[string]Account        `toml:"accounts"`
+	Subscriptions map[string][]Subscription `toml:"subscriptions"`
+	Repositories  map[string]Repository     `toml:"repositories"`
+	// AdminChannel 権限変更などの監査用通知を送るチャンネル
+	AdminChannel string `toml:"admin_channel"`
+	// ShortRepositoryName 投稿にオーナーを省いたリポジトリ名を表示する
+	ShortRepositoryName bool `toml:"short_repository_name"`
+	// Templates "event.action" または "event" をキーとした投稿用テンプレート (text/template)
+	// "default" を指定すると組み込みのレイアウトを置き換える
+	// サマリの項目はmrkdwn向けにエスケープ済みで渡すので、escape はテンプレートに書いた文字列や組み立てた文字列に使う
+	Templates map[string]string `toml:"templates"`
+	// BodyLimit 投稿する本文の長さの上限
+	BodyLimit BodyLimit `toml:"body_limit"`
+	// SlackToken users.lookupByEmail を呼ぶためのSlackのトークン
+	SlackToken string `toml:"slack_token"`
+	// SlackCacheFile 解決したSlackユーザーIDのキャッシュファイル
+	SlackCacheFile string `toml:"slack_cache_file"`
+	// SlackSigningSecret スラッシュコマンドなどSlackからのリクエストの署名シークレット
+	SlackSigningSecret string `toml:"slack_signing_secret"`
+	// StoreFile スラッシュコマンドで登録したアカウント連携の保存先
+	StoreFile string `toml:"store_file"`
+	// GithubToken Slackからの返信をGithubへ投稿するためのトークン
+	GithubToken string `toml:"github_token"`
+	// GithubAppID トークンの代わりにGithub Appとして投稿する場合のAppのID
+	GithubAppID int64 `toml:"github_app_id"`
+	// GithubAppPrivateKeyFile Github Appの秘密鍵 (PEM) のファイル
+	GithubAppPrivateKeyFile string `toml:"github_app_private_key_file"`
+	// Urgent 静かな時間帯でもすぐに送るイベント
+	Urgent []UrgentRule `toml:"urgent"`
+}
+
+// minBodyLimitChars 本文の文字数の上限に指定できる最小値
+// これより小さいと続きへのリンクなどで本文が残らない
+const minBodyLimitChars = 50
+
+// ErrBodyLimitTooSmall 本文の文字数の上限が小さすぎる
+var ErrBodyLimitTooSmall = errors.New("body_limit_too_small")
+
+// BodyLimit 本文の長さの上限
+// 0 の項目は制限しないが、Slackの上限を超える長さは常に切り詰める
+type BodyLimit struct {
+	Chars int `toml:"chars"`
+	Lines int `toml:"lines"`
 }
 
 // Account Slackアカウント情報
+// ID が未設定の場合は Email からSlackユーザーIDを解決する
 type Account struct {
 	ID      string `toml:"id"`
 	Channel string `toml:"channel"`
+	Email   string `toml:"email"`
+	// MinSeverity オーナーとして受け取るセキュリティアラートの最低重要度
+	MinSeverity string `toml:"min_severity"`
+	// Labels 作成時やラベル付与時に通知を受け取るラベルの購読設定
+	Labels []LabelSubscription `toml:"labels"`
+	// Timezone QuietHours を解釈するタイムゾーン ("Asia/Tokyo" など、省略時はサーバのタイムゾーン)
+	Timezone string `toml:"timezone"`
+	// QuietHours 通知を溜めておく時間帯 ("22:00-08:00" など)
+	// 溜めた通知は時間帯が明けてからまとめて送る
+	QuietHours string `toml:"quiet_hours"`
+}
+
+// LabelSubscription ラベルの購読設定
+// repository, label ともにglobパターンで指定でき、repository が空の場合は全リポジトリが対象
+// repository は "owner/repo" のフルネームでもリポジトリ名だけでもよい
+type LabelSubscription struct {
+	Repository string `toml:"repository"`
+	Label      string `toml:"label"`
+}
+
+// Match リポジトリとラベルが購読対象かどうか
+func (l LabelSubscription) Match(repository string, label string) bool {
+	if l.Repository != "" {
+		if ok, _ := path.Match(l.Repository, repository); !ok {
+			return false
+		}
+	}
+	ok, _ := path.Match(l.Label, label)
+	return ok
+}
+
+// Subscription リポジトリ毎のチャンネル購読設定
+// メンションによる個人宛の通知とは別に、指定したイベントをチャンネルへ送る
+type Subscription struct {
+	Channel string   `toml:"channel"`
+	Events  []string `toml:"events"`
+	// MinSeverity セキュリティアラートを送る最低重要度
+	MinSeverity string `toml:"min_severity"`
+	// Refs 送るブランチやタグのglobパターン ("branch:release/*", "tag:v*" など)
+	Refs []string `toml:"refs"`
+}
+
+// MatchRef ブランチやタグが購読対象かどうか
+// パターン未指定、またはブランチやタグを持たないイベントは常に対象とする
+func (s Subscription) MatchRef(refType string, ref string) bool {
+	if len(s.Refs) == 0 || ref == "" {
+		return true
+	}
+	for _, pattern := range s.Refs {
+		if matchRef(pattern, refType, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// Repository リポジトリ毎の設定
+type Repository struct {
+	// Owners セキュリティアラートを受け取るGithubアカウント(@付き)
+	Owners []string `toml:"owners"`
+}
+
+// UrgentRule 静かな時間帯でもすぐに送るイベントの設定
+type UrgentRule struct {
+	// Events "event" または "event.action" の一覧
+	Events []string `toml:"events"`
+	// Refs 対象のブランチやタグのglobパターン (プルリクエストはブランチで判定する)
+	Refs []string `toml:"refs"`
+}
+
+// Match イベントがすぐに送る対象かどうか
+func (u UrgentRule) Match(summary EventSummary) bool {
+	matched := false
+	for _, event := range u.Events {
+		if event == summary.Event || event == summary.Event+"."+summary.Action {
+			matched = true
+		}
+	}
+	if !matched {
+		return false
+	}
+	if len(u.Refs) == 0 {
+		return true
+	}
+	refType, ref := summary.RefType, summary.Ref
+	if summary.HeadRef != "" {
+		refType, ref = "branch", summary.HeadRef
+	}
+	for _, pattern := range u.Refs {
+		if matchRef(pattern, refType, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUrgent イベントが静かな時間帯でもすぐに送る対象かどうか
+func (c Config) IsUrgent(summary EventSummary) bool {
+	for _, rule := range c.Urgent {
+		if rule.Match(summary) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate 起動時に検出したい設定の誤りを調べる
+func (c Config) Validate() error {
+	if c.BodyLimit.Chars > 0 && c.BodyLimit.Chars < minBodyLimitChars {
+		return fmt.Errorf("body_limit.chars: %v (minimum %v)", ErrBodyLimitTooSmall, minBodyLimitChars)
+	}
+	for key, account := range c.Accounts {
+		if _, err := account.location(); err != nil {
+			return fmt.Errorf("%v: %v", key, err)
+		}
+		if _, err := parseQuietHours(account.QuietHours); err != nil {
+			return fmt.Errorf("%v: %v", key, err)
+		}
+		if !validThreshold(account.MinSeverity) {
+			return fmt.Errorf("%v: %v", key, ErrInvalidSeverity)
+		}
+	}
+	for key, subscriptions := range c.Subscriptions {
+		for _, subscription := range subscriptions {
+			if !validThreshold(subscription.MinSeverity) {
+				return fmt.Errorf("%v: %v", key, ErrInvalidSeverity)
+			}
+		}
+	}
+	return nil
 }
 
 // ParseFile 設定ファイルをパースする関数
@@ -38,3 +38,60 @@ func TestParseFile(t *testing.T) {
 		t.Fatal("failed: parse account.Channel", account.Channel)
 	}
 }
+
+func TestParseFileSubscriptions(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "test")
+	if err != nil {
+		t.Fatal("failed: create tmp file", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	content := []byte("[[subscriptions.\"repo\"]]\nchannel = \"aaa\"\nevents = [\"release\", \"issues\"]")
+	if _, err := tmpFile.Write(content); err != nil {
+		t.Fatal(err)
+	}
+
+	config := Config{}
+	err = config.ParseFile(tmpFile.Name())
+	if err != nil {
+		t.Fatal("failed: parse file", err)
+	}
+	subscriptions, ok := config.Subscriptions["repo"]
+	if !ok || len(subscriptions) != 1 {
+		t.Fatal("failed: parse Subscriptions")
+	}
+	if subscriptions[0].Channel != "aaa" {
+		t.Fatal("failed: parse subscription.Channel", subscriptions[0].Channel)
+	}
+	if len(subscriptions[0].Events) != 2 || subscriptions[0].Events[0] != "release" {
+		t.Fatal("failed: parse subscription.Events", subscriptions[0].Events)
+	}
+}
+
+func TestParseFileLabels(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "test")
+	if err != nil {
+		t.Fatal("failed: create tmp file", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	content := []byte("[accounts.\"bbb\"]\nid = \"ccc\"\n[[accounts.\"bbb\".labels]]\nrepository = \"eee\"\nlabel = \"fff\"")
+	if _, err := tmpFile.Write(content); err != nil {
+		t.Fatal(err)
+	}
+
+	config := Config{}
+	err = config.ParseFile(tmpFile.Name())
+	if err != nil {
+		t.Fatal("failed: parse file", err)
+	}
+	account, ok := config.Accounts["bbb"]
+	if !ok {
+		t.Fatal("failed: parse Accounts")
+	}
+	if len(account.Labels) != 1 || account.Labels[0].Repository != "eee" || account.Labels[0].Label != "fff" {
+		t.Fatal("failed: parse account.Labels", account.Labels)
+	}
+}
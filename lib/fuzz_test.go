@@ -0,0 +1,25 @@
+//go:build go1.18
+// +build go1.18
+
+package lib
+
+import (
+	"testing"
+)
+
+func FuzzParseEventSummary(f *testing.F) {
+	for event, actions := range SupportedEvents() {
+		f.Add(event, []byte(`{}`))
+		f.Add(event, []byte(`null`))
+		for _, action := range actions {
+			f.Add(event, []byte(`{"action": "`+action+`", "repository": {}}`))
+		}
+	}
+	f.Add("issues", []byte(`{"action": "opened", "repository": {"name": "r"}, "issue": {"body": null, "user": null}}`))
+	f.Add("pull_request", []byte(`{"action": "labeled", "repository": {}, "pull_request": {}, "label": {}}`))
+
+	f.Fuzz(func(t *testing.T, event string, payload []byte) {
+		summary := EventSummary{}
+		summary.ParseEventSummary(HookContext{Event: event, Payload: payload})
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/google/go-github/github"
@@ -34,15 +35,176 @@ var (
 	ErrEmptyPayload     = errors.New("empty_payload")
 	ErrUnhandledEvent   = errors.New("unhandled_event")
 	ErrUnhandledAction  = errors.New("unhandled_action")
+	ErrIgnoredEvent     = errors.New("ignored_event")
 )
 
+// MissingFieldError ペイロードに必須項目がない場合のエラー
+type MissingFieldError struct {
+	Event string
+	Field string
+}
+
+func (e *MissingFieldError) Error() string {
+	if e.Event == "" {
+		return "missing_field: " + e.Field
+	}
+	return "missing_field: " + e.Event + "." + e.Field
+}
+
+// missingField 必須項目がない場合のエラーを生成する
+func missingField(field string) error {
+	return &MissingFieldError{Field: field}
+}
+
+// stringValue nilを空文字として文字列を取得する
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// setRepository リポジトリの情報を設定する
+func (summary *EventSummary) setRepository(repo *github.Repository) {
+	summary.RepositoryName = repo.GetName()
+	summary.RepositoryFullName = repo.GetFullName()
+	summary.RepositoryOwner = repo.GetOwner().GetLogin()
+	summary.RepositoryURL = repo.GetHTMLURL()
+}
+
+// timeValue nilをゼロ値として日時を取得する
+func timeValue(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// intValue nilを0として数値を取得する
+func intValue(i *int) int {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
+
+// pullRequestEvent pull_requestイベントのペイロード
+// 依存しているgo-githubの定義にはラベルとレビュー依頼先がないので追加する
+type pullRequestEvent struct {
+	github.PullRequestEvent
+	PullRequest       *pullRequest  `json:"pull_request,omitempty"`
+	Label             *github.Label `json:"label,omitempty"`
+	RequestedReviewer *github.User  `json:"requested_reviewer,omitempty"`
+}
+
+// pullRequestReviewCommentEvent pull_request_review_commentイベントのペイロード
+// 依存しているgo-githubの定義には行番号がないので追加する
+type pullRequestReviewCommentEvent struct {
+	github.PullRequestReviewCommentEvent
+	Comment *pullRequestComment `json:"comment,omitempty"`
+}
+
+// pullRequestComment 行番号付きのレビューコメント
+type pullRequestComment struct {
+	github.PullRequestComment
+	Line              *int `json:"line,omitempty"`
+	OriginalLine      *int `json:"original_line,omitempty"`
+	StartLine         *int `json:"start_line,omitempty"`
+	OriginalStartLine *int `json:"original_start_line,omitempty"`
+	// InReplyToID go-githubのこの版は in_reply_to で読むため別に持つ
+	InReplyToID *int64 `json:"in_reply_to_id,omitempty"`
+}
+
+// pullRequest ラベル付きのプルリクエスト
+type pullRequest struct {
+	github.PullRequest
+	Labels []github.Label `json:"labels,omitempty"`
+}
+
+func init() {
+	RegisterEventParser(summaryParser{"issues", []string{"opened", "edited", "labeled"}, (*EventSummary).parseIssuesEvent})
+	RegisterEventParser(summaryParser{"issue_comment", []string{"created", "edited"}, (*EventSummary).parseIssueCommentsEvent})
+	RegisterEventParser(summaryParser{"pull_request", []string{"opened", "edited", "labeled", "review_requested"}, (*EventSummary).parsePullRequestEvent})
+	RegisterEventParser(summaryParser{"pull_request_review", []string{"submitted", "edited"}, (*EventSummary).parsePullRequestReviewEvent})
+	RegisterEventParser(summaryParser{"pull_request_review_comment", []string{"created", "edited"}, (*EventSummary).parsePullRequestReviewCommentEvent})
+}
+
+// Actor イベントを起こしたGithubユーザー
+type Actor struct {
+	Login     string
+	AvatarURL string
+	// Email 公開されている場合のみ入る
+	Email string
+}
+
+// actorOf Githubユーザーからイベントの起点となったユーザー情報を生成する
+func actorOf(user *github.User) Actor {
+	return Actor{Login: user.GetLogin(), AvatarURL: user.GetAvatarURL(), Email: user.GetEmail()}
+}
+
 // EventSummary githubイベントサマリ
+// 通知文面はレンダラで組み立てるので、ここでは構造化した情報だけを持つ
 type EventSummary struct {
+	Event  string
+	Action string
+	// Kind 通知対象の種類 (issue, pull_request, comment など)
+	Kind string
+	// Actor イベントを起こしたユーザー
+	Actor          Actor
 	RepositoryName string
-	Title          string
-	URL            string
-	Description    string
-	Comment        string
+	// RepositoryFullName "owner/repo" 形式のリポジトリ名
+	RepositoryFullName string
+	// RepositoryOwner リポジトリのオーナー (ユーザーまたはOrganization)
+	RepositoryOwner string
+	// RepositoryURL リポジトリのURL
+	RepositoryURL string
+	// Number Issueやプルリクエストなどの番号
+	Number int
+	// State 対象の状態 (open, closed, failure など)
+	State string
+	Title string
+	URL   string
+	// Comment 本文 (メンションの検出対象)
+	Comment string
+	// Labels 付与されたラベル名
+	Labels []string
+	// CreatedAt 対象の作成日時
+	CreatedAt time.Time
+	// Ref 対象のブランチやタグ
+	Ref string
+	// RefType Refの種類 (branch, tag)
+	RefType string
+	// HeadRef プルリクエストのブランチ
+	HeadRef string
+	// Environment デプロイ先の環境
+	Environment string
+	// Path コメント対象のファイルパス
+	Path string
+	// Line コメント対象の行番号 (範囲の場合は最終行)
+	Line int
+	// StartLine 複数行へのコメントの開始行番号
+	StartLine int
+	// DiffHunk レビューコメント対象の差分
+	DiffHunk string
+	// CodeContext DiffHunk の末尾をコードブロックにしたもの (描画時に設定する)
+	CodeContext string
+	// CommitID コメント対象のコミットSHA
+	CommitID string
+	// ReviewThreadID レビューコメントのスレッドの先頭コメントのID (Slackからの返信先)
+	ReviewThreadID int64
+	// Severity セキュリティアラートの重要度
+	Severity string
+	// ChannelOnly 本文のメンションを個人宛の通知に使わない (リリースノートなど)
+	ChannelOnly bool
+	// Recipients メンションに関係なく通知するGithubアカウント(@付き)またはメールアドレス
+	Recipients []string
+	// ciKey CIの結果の履歴を引くキー (CIのイベントのみ)
+	ciKey string
+	// ciRunKey 同じCIの実行の重複した通知を防ぐキー (CIのイベントのみ)
+	ciRunKey string
+	// mentions ReplaceComment で埋め込んだSlackのメンション
+	// レンダリング時のエスケープ対象から外すために記録しておく
+	mentions []string
 }
 
 // ParseHook Githubのリクエストをパースする関数
@@ -104,10 +266,158 @@ func FindAccounts(comment string, conf Config) map[string]Account {
 	return accounts
 }
 
+// FindMentionedAccounts 本文でメンションされたアカウント一覧を取得する
+// チャンネルにだけ送る種類のイベントは個人宛に送らない
+func FindMentionedAccounts(summary EventSummary, conf Config) map[string]Account {
+	if summary.ChannelOnly {
+		return map[string]Account{}
+	}
+	return FindAccounts(summary.Comment, conf)
+}
+
+// FindRecipientAccounts 通知対象のGithubアカウントに対応するアカウント情報一覧を取得する
+// メールアドレスの通知対象は、同じメールアドレスを設定したアカウントを探す
+func FindRecipientAccounts(recipients []string, conf Config) map[string]Account {
+	accounts := map[string]Account{}
+	for _, recipient := range recipients {
+		if account, ok := conf.Accounts[recipient]; ok {
+			accounts[recipient] = account
+			continue
+		}
+		if strings.HasPrefix(recipient, "@") {
+			continue
+		}
+		for key, account := range conf.Accounts {
+			if account.Email != "" && strings.EqualFold(account.Email, recipient) {
+				accounts[key] = account
+			}
+		}
+	}
+	return accounts
+}
+
+// threadEvents IssueやプルリクエストのイベントでNumberがその番号になるもの
+// ワークフローやアラートなどの番号は別の採番なので含めない
+var threadEvents = map[string]bool{
+	"issues":                      true,
+	"issue_comment":               true,
+	"pull_request":                true,
+	"pull_request_review":         true,
+	"pull_request_review_comment": true,
+}
+
+// ThreadKey Issueやプルリクエスト単位の通知をまとめるキー ("owner/repo#12")
+// Issueやプルリクエスト以外のイベントでは空文字
+func (summary EventSummary) ThreadKey() string {
+	keys := summary.repositoryKeys()
+	if !threadEvents[summary.Event] || summary.Number == 0 || len(keys) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%v#%v", keys[0], summary.Number)
+}
+
+// repositoryKeys リポジトリ単位の設定を引くためのキー一覧
+// "owner/repo" のフルネームでも従来のリポジトリ名でも設定できる
+func (summary EventSummary) repositoryKeys() []string {
+	keys := []string{}
+	if summary.RepositoryFullName != "" {
+		keys = append(keys, summary.RepositoryFullName)
+	}
+	if summary.RepositoryName != "" && summary.RepositoryName != summary.RepositoryFullName {
+		keys = append(keys, summary.RepositoryName)
+	}
+	return keys
+}
+
+// FindSubscribers イベントを購読しているチャンネルをアカウント情報一覧として取得する
+func FindSubscribers(summary EventSummary, conf Config) map[string]Account {
+	accounts := map[string]Account{}
+	subscriptions := []Subscription{}
+	for _, key := range summary.repositoryKeys() {
+		subscriptions = append(subscriptions, conf.Subscriptions[key]...)
+	}
+	for _, subscription := range subscriptions {
+		if !severityAtLeast(summary.Severity, subscription.MinSeverity) {
+			continue
+		}
+		if !subscription.MatchRef(summary.RefType, summary.Ref) {
+			continue
+		}
+		for _, event := range subscription.Events {
+			if event == summary.Event {
+				accounts[subscription.Channel] = Account{Channel: subscription.Channel}
+			}
+		}
+	}
+	return accounts
+}
+
+// FindOwners セキュリティアラートを受け取るリポジトリオーナーのアカウント情報一覧を取得する
+func FindOwners(summary EventSummary, conf Config) map[string]Account {
+	accounts := map[string]Account{}
+	if !securityEvents[summary.Event] {
+		return accounts
+	}
+	for _, key := range summary.repositoryKeys() {
+		for _, owner := range conf.Repositories[key].Owners {
+			account, ok := conf.Accounts[owner]
+			if ok && severityAtLeast(summary.Severity, account.MinSeverity) {
+				accounts[owner] = account
+			}
+		}
+	}
+	return accounts
+}
+
+// FindAdminChannel 監査用の通知先チャンネルをアカウント情報一覧として取得する
+func FindAdminChannel(summary EventSummary, conf Config) map[string]Account {
+	accounts := map[string]Account{}
+	if auditEvents[summary.Event] && conf.AdminChannel != "" {
+		accounts[conf.AdminChannel] = Account{Channel: conf.AdminChannel}
+	}
+	return accounts
+}
+
+// FindLabelSubscribers 付与されたラベルを購読しているアカウント情報一覧を取得する
+// 対象は作成時とラベル付与時のみ
+func FindLabelSubscribers(summary EventSummary, conf Config) map[string]Account {
+	accounts := map[string]Account{}
+	if summary.Action != "opened" && summary.Action != "labeled" {
+		return accounts
+	}
+	for key, account := range conf.Accounts {
+		for _, subscription := range account.Labels {
+			for _, label := range summary.Labels {
+				for _, repository := range summary.repositoryKeys() {
+					if subscription.Match(repository, label) {
+						accounts[key] = account
+					}
+				}
+			}
+		}
+	}
+	return accounts
+}
+
+// labelNames ラベル名一覧を取得する
+func labelNames(labels []github.Label) []string {
+	names := []string{}
+	for _, label := range labels {
+		names = append(names, label.GetName())
+	}
+	return names
+}
+
 // ReplaceComment コメント内のアカウント情報を置き換える関数
 func (summary *EventSummary) ReplaceComment(accounts map[string]Account) {
 	for key, account := range accounts {
-		summary.Comment = strings.Replace(summary.Comment, key, "<"+account.ID+">", -1)
+		// SlackユーザーIDが分からないアカウントはそのまま残す
+		if account.ID == "" {
+			continue
+		}
+		mention := "<" + account.ID + ">"
+		summary.Comment = strings.Replace(summary.Comment, key, mention, -1)
+		summary.mentions = append(summary.mentions, mention)
 	}
 }
 
@@ -118,14 +428,35 @@ func (summary *EventSummary) parseIssuesEvent(payload []byte) error {
 	if err != nil {
 		return err
 	}
-	if *evt.Action != "opened" && *evt.Action != "edited" {
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	if evt.Issue == nil {
+		return missingField("issue")
+	}
+	switch evt.GetAction() {
+	case "opened", "edited":
+		summary.Actor = actorOf(evt.Issue.GetUser())
+		summary.Comment = evt.Issue.GetBody()
+		summary.Labels = labelNames(evt.Issue.Labels)
+	case "labeled":
+		if evt.Label == nil {
+			return missingField("label")
+		}
+		// 本文のメンションへ再通知しないようにラベルの情報だけを送る
+		summary.Actor = actorOf(evt.GetSender())
+		summary.Labels = []string{evt.Label.GetName()}
+	default:
 		return ErrUnhandledAction
 	}
-	summary.RepositoryName = *evt.Repo.Name
-	summary.Title = *evt.Issue.Title
-	summary.URL = *evt.Issue.HTMLURL
-	summary.Description = fmt.Sprintf("Issue %v by: %v", *evt.Action, *evt.Issue.User.Login)
-	summary.Comment = *evt.Issue.Body
+	summary.setRepository(evt.Repo)
+	summary.Action = evt.GetAction()
+	summary.Kind = "issue"
+	summary.Number = evt.Issue.GetNumber()
+	summary.State = evt.Issue.GetState()
+	summary.Title = evt.Issue.GetTitle()
+	summary.URL = evt.Issue.GetHTMLURL()
+	summary.CreatedAt = evt.Issue.GetCreatedAt()
 	return nil
 }
 
@@ -136,32 +467,76 @@ func (summary *EventSummary) parseIssueCommentsEvent(payload []byte) error {
 	if err != nil {
 		return err
 	}
-	if *evt.Action != "created" && *evt.Action != "edited" {
+	if evt.GetAction() != "created" && evt.GetAction() != "edited" {
 		return ErrUnhandledAction
 	}
-	summary.RepositoryName = *evt.Repo.Name
-	summary.Title = *evt.Issue.Title
-	summary.URL = *evt.Comment.HTMLURL
-	summary.Description = fmt.Sprintf("Comment %v by: %v", *evt.Action, *evt.Comment.User.Login)
-	summary.Comment = *evt.Comment.Body
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	if evt.Issue == nil {
+		return missingField("issue")
+	}
+	if evt.Comment == nil {
+		return missingField("comment")
+	}
+	summary.setRepository(evt.Repo)
+	summary.Action = evt.GetAction()
+	summary.Kind = "comment"
+	summary.Actor = actorOf(evt.Comment.GetUser())
+	summary.Number = evt.Issue.GetNumber()
+	summary.State = evt.Issue.GetState()
+	summary.Title = evt.Issue.GetTitle()
+	summary.URL = evt.Comment.GetHTMLURL()
+	summary.Comment = evt.Comment.GetBody()
+	summary.Labels = labelNames(evt.Issue.Labels)
+	summary.CreatedAt = evt.Comment.GetCreatedAt()
 	return nil
 }
 
 // parsePullRequestEvent pull_requestイベントをパースする
 func (summary *EventSummary) parsePullRequestEvent(payload []byte) error {
-	evt := github.PullRequestEvent{}
+	evt := pullRequestEvent{}
 	err := json.Unmarshal(payload, &evt)
 	if err != nil {
 		return err
 	}
-	if *evt.Action != "opened" && *evt.Action != "edited" {
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	if evt.PullRequest == nil {
+		return missingField("pull_request")
+	}
+	switch evt.GetAction() {
+	case "opened", "edited":
+		summary.Actor = actorOf(evt.PullRequest.GetUser())
+		summary.Comment = evt.PullRequest.GetBody()
+		summary.Labels = labelNames(evt.PullRequest.Labels)
+	case "labeled":
+		if evt.Label == nil {
+			return missingField("label")
+		}
+		summary.Actor = actorOf(evt.GetSender())
+		summary.Labels = []string{evt.Label.GetName()}
+	case "review_requested":
+		// チームへの依頼は個人宛にせず、購読の通知だけにする
+		summary.Actor = actorOf(evt.GetSender())
+		if evt.RequestedReviewer != nil {
+			summary.Recipients = []string{"@" + evt.RequestedReviewer.GetLogin()}
+		}
+	default:
 		return ErrUnhandledAction
 	}
-	summary.RepositoryName = *evt.Repo.Name
-	summary.Title = *evt.PullRequest.Title
-	summary.URL = *evt.PullRequest.HTMLURL
-	summary.Description = fmt.Sprintf("PullRequest %v by: %v", *evt.Action, *evt.PullRequest.User.Login)
-	summary.Comment = *evt.PullRequest.Body
+	summary.setRepository(evt.Repo)
+	summary.Action = evt.GetAction()
+	summary.Kind = "pull_request"
+	if evt.PullRequest.Head != nil {
+		summary.HeadRef = evt.PullRequest.Head.GetRef()
+	}
+	summary.Number = evt.PullRequest.GetNumber()
+	summary.State = evt.PullRequest.GetState()
+	summary.Title = evt.PullRequest.GetTitle()
+	summary.URL = evt.PullRequest.GetHTMLURL()
+	summary.CreatedAt = evt.PullRequest.GetCreatedAt()
 	return nil
 }
 
@@ -174,49 +549,87 @@ func (summary *EventSummary) parsePullRequestReviewEvent(payload []byte) error {
 	}
 	// コードコメントを含んだレビューのサブミットを行うと submitted と edited 両方がイベントとして投げられる
 	// -> github側がそうなっているので仕方ない
-	if *evt.Action != "submitted" && *evt.Action != "edited" {
+	if evt.GetAction() != "submitted" && evt.GetAction() != "edited" {
 		return ErrUnhandledAction
 	}
-	summary.RepositoryName = *evt.Repo.Name
-	summary.Title = *evt.PullRequest.Title
-	summary.URL = *evt.Review.HTMLURL
-	summary.Description = fmt.Sprintf("Review %v by: %v", *evt.Action, *evt.Review.User.Login)
-	summary.Comment = *evt.Review.Body
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	if evt.PullRequest == nil {
+		return missingField("pull_request")
+	}
+	if evt.Review == nil {
+		return missingField("review")
+	}
+	summary.setRepository(evt.Repo)
+	summary.Action = evt.GetAction()
+	summary.Kind = "review"
+	summary.Actor = actorOf(evt.Review.GetUser())
+	summary.Number = evt.PullRequest.GetNumber()
+	summary.State = strings.ToLower(evt.Review.GetState())
+	summary.Title = evt.PullRequest.GetTitle()
+	summary.URL = evt.Review.GetHTMLURL()
+	summary.Comment = evt.Review.GetBody()
+	summary.CreatedAt = evt.Review.GetSubmittedAt()
 	return nil
 }
 
 // parsePullRequestReviewCommentEvent pull_request_review_commentイベントをパースする
 func (summary *EventSummary) parsePullRequestReviewCommentEvent(payload []byte) error {
-	evt := github.PullRequestReviewCommentEvent{}
+	evt := pullRequestReviewCommentEvent{}
 	err := json.Unmarshal(payload, &evt)
 	if err != nil {
 		return err
 	}
-	if *evt.Action != "created" && *evt.Action != "edited" {
+	if evt.GetAction() != "created" && evt.GetAction() != "edited" {
 		return ErrUnhandledAction
 	}
-	summary.RepositoryName = *evt.Repo.Name
-	summary.Title = *evt.PullRequest.Title
-	summary.URL = *evt.Comment.HTMLURL
-	summary.Description = fmt.Sprintf("Comment %v by: %v", *evt.Action, *evt.Comment.User.Login)
-	summary.Comment = *evt.Comment.Body
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	if evt.PullRequest == nil {
+		return missingField("pull_request")
+	}
+	if evt.Comment == nil {
+		return missingField("comment")
+	}
+	summary.setRepository(evt.Repo)
+	summary.Action = evt.GetAction()
+	summary.Kind = "comment"
+	summary.Actor = actorOf(evt.Comment.GetUser())
+	summary.Number = evt.PullRequest.GetNumber()
+	summary.State = evt.PullRequest.GetState()
+	summary.Title = evt.PullRequest.GetTitle()
+	summary.URL = evt.Comment.GetHTMLURL()
+	summary.Comment = evt.Comment.GetBody()
+	summary.CreatedAt = evt.Comment.GetCreatedAt()
+	summary.Path = evt.Comment.GetPath()
+	summary.CommitID = evt.Comment.GetCommitID()
+	summary.DiffHunk = evt.Comment.GetDiffHunk()
+	summary.ReviewThreadID = evt.Comment.GetID()
+	if evt.Comment.InReplyToID != nil {
+		summary.ReviewThreadID = *evt.Comment.InReplyToID
+	}
+	// 古くなったコメントは現在の行番号を持たないので元の行番号を使う
+	summary.Line = intValue(evt.Comment.Line)
+	summary.StartLine = intValue(evt.Comment.StartLine)
+	if evt.Comment.Line == nil {
+		summary.Line = intValue(evt.Comment.OriginalLine)
+		summary.StartLine = intValue(evt.Comment.OriginalStartLine)
+	}
 	return nil
 }
 
 // ParseEventSummary githubイベントサマリ生成
+// 複数のサマリを生成するイベントでは先頭のサマリを使う
 func (summary *EventSummary) ParseEventSummary(hc HookContext) error {
-	switch hc.Event {
-	case "issues":
-		return summary.parseIssuesEvent(hc.Payload)
-	case "issue_comment":
-		return summary.parseIssueCommentsEvent(hc.Payload)
-	case "pull_request":
-		return summary.parsePullRequestEvent(hc.Payload)
-	case "pull_request_review":
-		return summary.parsePullRequestReviewEvent(hc.Payload)
-	case "pull_request_review_comment":
-		return summary.parsePullRequestReviewCommentEvent(hc.Payload)
-	default:
-		return ErrUnhandledEvent
+	summaries, err := ParseEventSummaries(hc)
+	if err != nil {
+		return err
+	}
+	if len(summaries) == 0 {
+		return ErrIgnoredEvent
 	}
+	*summary = summaries[0]
+	return nil
 }
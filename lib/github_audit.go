@@ -0,0 +1,225 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+func init() {
+	RegisterEventParser(summaryParser{"member", []string{"added", "removed", "edited"}, (*EventSummary).parseMemberEvent})
+	RegisterEventParser(summaryParser{"membership", []string{"added", "removed"}, (*EventSummary).parseMembershipEvent})
+	RegisterEventParser(summaryParser{"team", []string{"created", "deleted", "edited", "added_to_repository", "removed_from_repository"}, (*EventSummary).parseTeamEvent})
+	RegisterEventParser(summaryParser{"organization", []string{"member_added", "member_removed", "member_invited"}, (*EventSummary).parseOrganizationEvent})
+}
+
+// auditEvents 管理者チャンネルへ送る権限変更系のイベント
+var auditEvents = map[string]bool{
+	"member":       true,
+	"membership":   true,
+	"team":         true,
+	"organization": true,
+}
+
+// memberEvent memberイベントのペイロード
+// 依存しているgo-githubの定義には権限の変更内容がないので追加する
+type memberEvent struct {
+	github.MemberEvent
+	Changes *struct {
+		Permission *struct {
+			From *string `json:"from,omitempty"`
+			To   *string `json:"to,omitempty"`
+		} `json:"permission,omitempty"`
+	} `json:"changes,omitempty"`
+}
+
+// auditComment 監査用の詳細を組み立てる
+func auditComment(actor string, permission string) string {
+	comment := fmt.Sprintf("*Actor:* %v", actor)
+	if permission != "" {
+		comment = fmt.Sprintf("%v\n*Permission:* %v", comment, permission)
+	}
+	return comment
+}
+
+// teamURL チームのURL
+func teamURL(org string, slug string) string {
+	return fmt.Sprintf("https://github.com/orgs/%v/teams/%v", org, slug)
+}
+
+// teamPermission チームのリポジトリ権限の変更前の値を表示用に整える
+func teamPermission(changes *github.TeamChange) string {
+	if changes == nil || changes.Repository == nil || changes.Repository.Permissions == nil || changes.Repository.Permissions.From == nil {
+		return ""
+	}
+	from := changes.Repository.Permissions.From
+	permissions := []string{}
+	if from.Admin != nil && *from.Admin {
+		permissions = append(permissions, "admin")
+	}
+	if from.Push != nil && *from.Push {
+		permissions = append(permissions, "push")
+	}
+	if from.Pull != nil && *from.Pull {
+		permissions = append(permissions, "pull")
+	}
+	return strings.Join(permissions, ",")
+}
+
+// parseMemberEvent memberイベントをパースする
+func (summary *EventSummary) parseMemberEvent(payload []byte) error {
+	evt := memberEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	action := evt.GetAction()
+	if action != "added" && action != "removed" && action != "edited" {
+		return ErrUnhandledAction
+	}
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	if evt.Member == nil {
+		return missingField("member")
+	}
+	permission := ""
+	if evt.Changes != nil && evt.Changes.Permission != nil {
+		if evt.Changes.Permission.From != nil {
+			permission = *evt.Changes.Permission.From + " -> "
+		}
+		permission += stringValue(evt.Changes.Permission.To)
+	}
+	summary.setRepository(evt.Repo)
+	summary.Action = action
+	summary.Kind = "member"
+	summary.ChannelOnly = true
+	summary.Actor = actorOf(evt.GetSender())
+	summary.Title = fmt.Sprintf("%v %v as collaborator", evt.Member.GetLogin(), action)
+	summary.URL = evt.Repo.GetHTMLURL()
+	summary.Comment = auditComment(evt.GetSender().GetLogin(), permission)
+	return nil
+}
+
+// parseMembershipEvent membershipイベントをパースする
+func (summary *EventSummary) parseMembershipEvent(payload []byte) error {
+	evt := github.MembershipEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	action := evt.GetAction()
+	if action != "added" && action != "removed" {
+		return ErrUnhandledAction
+	}
+	if evt.Org == nil {
+		return missingField("organization")
+	}
+	if evt.Member == nil {
+		return missingField("member")
+	}
+	if evt.Team == nil {
+		return missingField("team")
+	}
+	org := evt.Org.GetLogin()
+	preposition := "to"
+	if action == "removed" {
+		preposition = "from"
+	}
+	summary.RepositoryName = org
+	summary.RepositoryOwner = org
+	summary.Action = action
+	summary.Kind = "membership"
+	summary.ChannelOnly = true
+	summary.Actor = actorOf(evt.GetSender())
+	summary.Title = fmt.Sprintf("%v %v %v team %v", evt.Member.GetLogin(), action, preposition, evt.Team.GetName())
+	summary.URL = teamURL(org, evt.Team.GetSlug())
+	summary.Comment = auditComment(evt.GetSender().GetLogin(), "")
+	return nil
+}
+
+// parseTeamEvent teamイベントをパースする
+func (summary *EventSummary) parseTeamEvent(payload []byte) error {
+	evt := github.TeamEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	if evt.Org == nil {
+		return missingField("organization")
+	}
+	if evt.Team == nil {
+		return missingField("team")
+	}
+	action := evt.GetAction()
+	org := evt.Org.GetLogin()
+	team := evt.Team.GetName()
+	permission := evt.Team.GetPermission()
+	switch action {
+	case "created", "deleted", "edited":
+		summary.Title = fmt.Sprintf("Team %v %v", team, action)
+		if from := teamPermission(evt.Changes); from != "" {
+			permission = fmt.Sprintf("%v -> %v", from, permission)
+		}
+	case "added_to_repository":
+		summary.Title = fmt.Sprintf("Team %v added to %v", team, evt.GetRepo().GetName())
+	case "removed_from_repository":
+		summary.Title = fmt.Sprintf("Team %v removed from %v", team, evt.GetRepo().GetName())
+	default:
+		return ErrUnhandledAction
+	}
+	summary.RepositoryName = org
+	summary.RepositoryOwner = org
+	summary.Action = action
+	summary.Kind = "team"
+	summary.ChannelOnly = true
+	summary.Actor = actorOf(evt.GetSender())
+	summary.URL = teamURL(org, evt.Team.GetSlug())
+	summary.Comment = auditComment(evt.GetSender().GetLogin(), permission)
+	return nil
+}
+
+// parseOrganizationEvent organizationイベントをパースする
+func (summary *EventSummary) parseOrganizationEvent(payload []byte) error {
+	evt := github.OrganizationEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	if evt.Organization == nil {
+		return missingField("organization")
+	}
+	action := evt.GetAction()
+	org := evt.Organization.GetLogin()
+	sender := evt.GetSender().GetLogin()
+	switch action {
+	case "member_added", "member_removed":
+		if evt.Membership == nil {
+			return missingField("membership")
+		}
+		summary.Title = fmt.Sprintf("%v %v", evt.Membership.GetUser().GetLogin(), strings.Replace(action, "_", " ", -1))
+		summary.Comment = auditComment(sender, evt.Membership.GetRole())
+	case "member_invited":
+		if evt.Invitation == nil {
+			return missingField("invitation")
+		}
+		invitee := evt.Invitation.GetLogin()
+		if invitee == "" {
+			invitee = evt.Invitation.GetEmail()
+		}
+		summary.Title = fmt.Sprintf("%v member invited", invitee)
+		summary.Comment = auditComment(sender, evt.Invitation.GetRole())
+	default:
+		return ErrUnhandledAction
+	}
+	summary.RepositoryName = org
+	summary.RepositoryOwner = org
+	summary.Action = action
+	summary.Kind = "organization"
+	summary.ChannelOnly = true
+	summary.Actor = actorOf(evt.GetSender())
+	summary.URL = fmt.Sprintf("https://github.com/orgs/%v/people", org)
+	return nil
+}
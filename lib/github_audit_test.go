@@ -0,0 +1,126 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestParseMemberEvent(t *testing.T) {
+	payload := `{
+		"action": "edited",
+		"member": {"login": "member"},
+		"changes": {"permission": {"from": "read", "to": "write"}},
+		"repository": {"name": "repo-name", "html_url": "repo-url"},
+		"sender": {"login": "admin"}
+	}`
+	summary := EventSummary{}
+	err := summary.parseMemberEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse action: edited", err)
+	}
+	if summary.RepositoryName != "repo-name" {
+		t.Fatal("failed: RepositoryName")
+	}
+	if summary.Title != "member edited as collaborator" {
+		t.Fatal("failed: Title", summary.Title)
+	}
+	if summary.URL != "repo-url" {
+		t.Fatal("failed: URL")
+	}
+	if describe(summary) != "Member edited by: admin" {
+		t.Fatal("failed: Description", describe(summary))
+	}
+	if summary.Comment != "*Actor:* admin\n*Permission:* read -> write" {
+		t.Fatal("failed: Comment", summary.Comment)
+	}
+}
+
+func TestParseMembershipEvent(t *testing.T) {
+	payload := `{
+		"action": "removed",
+		"scope": "team",
+		"member": {"login": "member"},
+		"team": {"name": "Owners", "slug": "owners"},
+		"organization": {"login": "org"},
+		"sender": {"login": "admin"}
+	}`
+	summary := EventSummary{}
+	err := summary.parseMembershipEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse action: removed", err)
+	}
+	if summary.RepositoryName != "org" {
+		t.Fatal("failed: RepositoryName")
+	}
+	if summary.Title != "member removed from team Owners" {
+		t.Fatal("failed: Title", summary.Title)
+	}
+	if summary.URL != "https://github.com/orgs/org/teams/owners" {
+		t.Fatal("failed: URL", summary.URL)
+	}
+}
+
+func TestParseTeamEvent(t *testing.T) {
+	payload := `{
+		"action": "added_to_repository",
+		"team": {"name": "Owners", "slug": "owners", "permission": "admin"},
+		"repository": {"name": "repo-name"},
+		"organization": {"login": "org"},
+		"sender": {"login": "admin"}
+	}`
+	summary := EventSummary{}
+	err := summary.parseTeamEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse action: added_to_repository", err)
+	}
+	if summary.Title != "Team Owners added to repo-name" {
+		t.Fatal("failed: Title", summary.Title)
+	}
+	if summary.Comment != "*Actor:* admin\n*Permission:* admin" {
+		t.Fatal("failed: Comment", summary.Comment)
+	}
+
+	payload = `{
+		"action": "edited",
+		"team": {"name": "Owners", "slug": "owners", "permission": "push"},
+		"changes": {"repository": {"permissions": {"from": {"admin": true, "push": true, "pull": true}}}},
+		"organization": {"login": "org"},
+		"sender": {"login": "admin"}
+	}`
+	summary = EventSummary{}
+	err = summary.parseTeamEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse action: edited", err)
+	}
+	if summary.Comment != "*Actor:* admin\n*Permission:* admin,push,pull -> push" {
+		t.Fatal("failed: Comment", summary.Comment)
+	}
+}
+
+func TestParseOrganizationEvent(t *testing.T) {
+	payload := `{
+		"action": "member_added",
+		"membership": {"role": "admin", "user": {"login": "member"}},
+		"organization": {"login": "org"},
+		"sender": {"login": "admin"}
+	}`
+	summary := EventSummary{}
+	err := summary.parseOrganizationEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse action: member_added", err)
+	}
+	if summary.Title != "member member added" {
+		t.Fatal("failed: Title", summary.Title)
+	}
+	if describe(summary) != "Organization member_added by: admin" {
+		t.Fatal("failed: Description", describe(summary))
+	}
+	if summary.Comment != "*Actor:* admin\n*Permission:* admin" {
+		t.Fatal("failed: Comment", summary.Comment)
+	}
+
+	summary = EventSummary{}
+	err = summary.parseOrganizationEvent([]byte(`{"action": "renamed", "organization": {"login": "org"}}`))
+	if err != ErrUnhandledAction {
+		t.Fatal("failed: unexpected error")
+	}
+}
@@ -0,0 +1,258 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+func init() {
+	RegisterEventParser(summaryParser{"workflow_run", []string{"completed"}, (*EventSummary).parseWorkflowRunEvent})
+	RegisterEventParser(summaryParser{"check_suite", []string{"completed"}, (*EventSummary).parseCheckSuiteEvent})
+	RegisterEventParser(summaryParser{"check_run", []string{"completed"}, (*EventSummary).parseCheckRunEvent})
+}
+
+// workflowRunEvent workflow_runイベントのペイロード
+// 依存しているgo-githubには定義がないので必要な項目だけ用意する
+type workflowRunEvent struct {
+	Action      *string            `json:"action,omitempty"`
+	WorkflowRun *workflowRun       `json:"workflow_run,omitempty"`
+	Repo        *github.Repository `json:"repository,omitempty"`
+	Sender      *github.User       `json:"sender,omitempty"`
+}
+
+// workflowRun workflow_runイベントの実行情報
+type workflowRun struct {
+	Name            *string      `json:"name,omitempty"`
+	RunNumber       *int         `json:"run_number,omitempty"`
+	HeadBranch      *string      `json:"head_branch,omitempty"`
+	HeadSHA         *string      `json:"head_sha,omitempty"`
+	Status          *string      `json:"status,omitempty"`
+	Conclusion      *string      `json:"conclusion,omitempty"`
+	HTMLURL         *string      `json:"html_url,omitempty"`
+	Actor           *github.User `json:"actor,omitempty"`
+	TriggeringActor *github.User `json:"triggering_actor,omitempty"`
+	HeadCommit      *headCommit  `json:"head_commit,omitempty"`
+	CreatedAt       *time.Time   `json:"created_at,omitempty"`
+	CheckSuiteID    *int64       `json:"check_suite_id,omitempty"`
+}
+
+// headCommit チェック対象となったコミット情報
+type headCommit struct {
+	ID      *string       `json:"id,omitempty"`
+	Message *string       `json:"message,omitempty"`
+	Author  *commitAuthor `json:"author,omitempty"`
+}
+
+// commitAuthor コミットの作成者
+// username はプッシュ時のペイロードにしかないので、なければメールアドレスで探す
+type commitAuthor struct {
+	Name     *string `json:"name,omitempty"`
+	Email    *string `json:"email,omitempty"`
+	Username *string `json:"username,omitempty"`
+}
+
+// checkSuiteEvent check_suiteイベントのペイロード
+type checkSuiteEvent struct {
+	Action     *string            `json:"action,omitempty"`
+	CheckSuite *checkSuite        `json:"check_suite,omitempty"`
+	Repo       *github.Repository `json:"repository,omitempty"`
+	Sender     *github.User       `json:"sender,omitempty"`
+}
+
+// checkSuite check_suiteイベントの実行情報
+type checkSuite struct {
+	ID         *int64      `json:"id,omitempty"`
+	HeadBranch *string     `json:"head_branch,omitempty"`
+	HeadSHA    *string     `json:"head_sha,omitempty"`
+	Status     *string     `json:"status,omitempty"`
+	Conclusion *string     `json:"conclusion,omitempty"`
+	App        *github.App `json:"app,omitempty"`
+	HeadCommit *headCommit `json:"head_commit,omitempty"`
+}
+
+// checkRunEvent check_runイベントのペイロード
+type checkRunEvent struct {
+	Action   *string            `json:"action,omitempty"`
+	CheckRun *checkRun          `json:"check_run,omitempty"`
+	Repo     *github.Repository `json:"repository,omitempty"`
+	Sender   *github.User       `json:"sender,omitempty"`
+}
+
+// checkRun check_runイベントの実行情報
+type checkRun struct {
+	Name       *string     `json:"name,omitempty"`
+	HeadSHA    *string     `json:"head_sha,omitempty"`
+	Status     *string     `json:"status,omitempty"`
+	Conclusion *string     `json:"conclusion,omitempty"`
+	HTMLURL    *string     `json:"html_url,omitempty"`
+	CheckSuite *checkSuite `json:"check_suite,omitempty"`
+}
+
+// isFailedConclusion 失敗扱いとする結果かどうか
+func isFailedConclusion(conclusion string) bool {
+	switch conclusion {
+	case "failure", "timed_out", "startup_failure":
+		return true
+	}
+	return false
+}
+
+// authorRecipients コミットの作成者を通知先にする
+// Githubアカウントが分からない場合はメールアドレスで設定のアカウントを探す
+func authorRecipients(commit *headCommit) []string {
+	if commit == nil || commit.Author == nil {
+		return nil
+	}
+	if username := stringValue(commit.Author.Username); username != "" {
+		return []string{"@" + username}
+	}
+	if email := stringValue(commit.Author.Email); email != "" {
+		return []string{email}
+	}
+	return nil
+}
+
+// ciRunKey 同じ実行を報告する workflow_run, check_suite, check_run で共通のキー
+// チェックスイートIDがなければCIの種類と名前、コミットで区別する
+func ciRunKey(suiteID *int64, ciKey string, sha string) string {
+	if suiteID != nil {
+		return fmt.Sprintf("suite:%v", *suiteID)
+	}
+	return ciKey + "@" + sha
+}
+
+// firstLine 複数行テキストの1行目を返す
+func firstLine(text string) string {
+	return strings.SplitN(text, "\n", 2)[0]
+}
+
+// parseWorkflowRunEvent workflow_runイベントをパースする
+func (summary *EventSummary) parseWorkflowRunEvent(payload []byte) error {
+	evt := workflowRunEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	if stringValue(evt.Action) != "completed" {
+		return ErrUnhandledAction
+	}
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	run := evt.WorkflowRun
+	if run == nil {
+		return missingField("workflow_run")
+	}
+	branch := stringValue(run.HeadBranch)
+	actor := run.Actor
+	if run.TriggeringActor != nil {
+		actor = run.TriggeringActor
+	}
+	summary.setRepository(evt.Repo)
+	summary.Action = stringValue(evt.Action)
+	summary.Kind = "workflow"
+	summary.Actor = actorOf(actor)
+	summary.Number = intValue(run.RunNumber)
+	summary.State = stringValue(run.Conclusion)
+	summary.CommitID = stringValue(run.HeadSHA)
+	summary.ciKey = fmt.Sprintf("workflow_run:%v:%v:%v", evt.Repo.GetFullName(), stringValue(run.Name), branch)
+	summary.ciRunKey = ciRunKey(run.CheckSuiteID, summary.ciKey, summary.CommitID)
+	summary.Title = fmt.Sprintf("%v #%v", stringValue(run.Name), summary.Number)
+	summary.URL = stringValue(run.HTMLURL)
+	if run.CreatedAt != nil {
+		summary.CreatedAt = *run.CreatedAt
+	}
+	if run.HeadCommit != nil {
+		summary.Comment = firstLine(stringValue(run.HeadCommit.Message))
+	}
+	summary.Ref = branch
+	summary.RefType = "branch"
+	if actor.GetLogin() != "" {
+		summary.Recipients = []string{"@" + actor.GetLogin()}
+	}
+	return nil
+}
+
+// parseCheckSuiteEvent check_suiteイベントをパースする
+func (summary *EventSummary) parseCheckSuiteEvent(payload []byte) error {
+	evt := checkSuiteEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	if stringValue(evt.Action) != "completed" {
+		return ErrUnhandledAction
+	}
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	suite := evt.CheckSuite
+	if suite == nil {
+		return missingField("check_suite")
+	}
+	branch := stringValue(suite.HeadBranch)
+	summary.setRepository(evt.Repo)
+	summary.Action = stringValue(evt.Action)
+	summary.Kind = "check_suite"
+	summary.Actor = actorOf(evt.Sender)
+	summary.State = stringValue(suite.Conclusion)
+	summary.ciKey = fmt.Sprintf("check_suite:%v:%v:%v", evt.Repo.GetFullName(), suite.App.GetName(), branch)
+	summary.Title = suite.App.GetName()
+	summary.URL = fmt.Sprintf("%v/commit/%v/checks", evt.Repo.GetHTMLURL(), stringValue(suite.HeadSHA))
+	summary.CommitID = stringValue(suite.HeadSHA)
+	summary.ciRunKey = ciRunKey(suite.ID, summary.ciKey, summary.CommitID)
+	if suite.HeadCommit != nil {
+		summary.Comment = firstLine(stringValue(suite.HeadCommit.Message))
+	}
+	summary.Ref = branch
+	summary.RefType = "branch"
+	summary.Recipients = authorRecipients(suite.HeadCommit)
+	return nil
+}
+
+// parseCheckRunEvent check_runイベントをパースする
+func (summary *EventSummary) parseCheckRunEvent(payload []byte) error {
+	evt := checkRunEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	if stringValue(evt.Action) != "completed" {
+		return ErrUnhandledAction
+	}
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	run := evt.CheckRun
+	if run == nil {
+		return missingField("check_run")
+	}
+	branch := ""
+	var commit *headCommit
+	if run.CheckSuite != nil {
+		branch = stringValue(run.CheckSuite.HeadBranch)
+		commit = run.CheckSuite.HeadCommit
+	}
+	summary.setRepository(evt.Repo)
+	summary.Action = stringValue(evt.Action)
+	summary.Kind = "check_run"
+	summary.Actor = actorOf(evt.Sender)
+	summary.State = stringValue(run.Conclusion)
+	summary.ciKey = fmt.Sprintf("check_run:%v:%v:%v", evt.Repo.GetFullName(), stringValue(run.Name), branch)
+	summary.Title = stringValue(run.Name)
+	summary.URL = stringValue(run.HTMLURL)
+	summary.CommitID = stringValue(run.HeadSHA)
+	var suiteID *int64
+	if run.CheckSuite != nil {
+		suiteID = run.CheckSuite.ID
+	}
+	summary.ciRunKey = ciRunKey(suiteID, summary.ciKey, summary.CommitID)
+	summary.Ref = branch
+	summary.RefType = "branch"
+	summary.Recipients = authorRecipients(commit)
+	return nil
+}
@@ -0,0 +1,301 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+func TestParseWorkflowRunEvent(t *testing.T) {
+	completed := "completed"
+	requested := "requested"
+	failure := "failure"
+	repoName := "repo-name"
+	fullName := "owner/repo-name"
+	workflowName := "CI"
+	runNumber := 12
+	branch := "feature"
+	htmlURL := "url"
+	user := "user"
+	message := "fix bug\n\ndetail"
+	evt := workflowRunEvent{
+		Action: &completed,
+		Repo: &github.Repository{
+			Name:     &repoName,
+			FullName: &fullName,
+		},
+		WorkflowRun: &workflowRun{
+			Name:       &workflowName,
+			RunNumber:  &runNumber,
+			HeadBranch: &branch,
+			HTMLURL:    &htmlURL,
+			Actor: &github.User{
+				Login: &user,
+			},
+			HeadCommit: &headCommit{
+				Message: &message,
+			},
+		},
+	}
+
+	evt.WorkflowRun.Conclusion = &failure
+	evtJSON, _ := json.Marshal(evt)
+	summary := EventSummary{}
+	err := summary.parseWorkflowRunEvent(evtJSON)
+	if err != nil {
+		t.Fatal("failed: parse conclusion: failure", err)
+	}
+	if summary.RepositoryName != repoName {
+		t.Fatal("failed: RepositoryName")
+	}
+	if summary.Title != "CI #12" {
+		t.Fatal("failed: Title", summary.Title)
+	}
+	if summary.URL != htmlURL {
+		t.Fatal("failed: URL")
+	}
+	if describe(summary) != "Workflow failure on feature by: user" {
+		t.Fatal("failed: Description", describe(summary))
+	}
+	if summary.Comment != "fix bug" {
+		t.Fatal("failed: Comment", summary.Comment)
+	}
+	if len(summary.Recipients) != 1 || summary.Recipients[0] != "@user" {
+		t.Fatal("failed: Recipients", summary.Recipients)
+	}
+	if summary.ciKey != "workflow_run:owner/repo-name:CI:feature" {
+		t.Fatal("failed: ciKey", summary.ciKey)
+	}
+
+	evt.Action = &requested
+	evtJSON, _ = json.Marshal(evt)
+	summary = EventSummary{}
+	err = summary.parseWorkflowRunEvent(evtJSON)
+	if err != ErrUnhandledAction {
+		t.Fatal("failed: unexpected error")
+	}
+}
+
+func TestParseCheckSuiteEvent(t *testing.T) {
+	completed := "completed"
+	failure := "failure"
+	repoName := "repo-name"
+	repoURL := "https://github.com/owner/repo-name"
+	appName := "app"
+	branch := "feature"
+	sha := "abc"
+	user := "user"
+	email := "author@example.com"
+	evt := checkSuiteEvent{
+		Action: &completed,
+		Repo: &github.Repository{
+			Name:    &repoName,
+			HTMLURL: &repoURL,
+		},
+		CheckSuite: &checkSuite{
+			HeadBranch: &branch,
+			HeadSHA:    &sha,
+			Conclusion: &failure,
+			App: &github.App{
+				Name: &appName,
+			},
+			HeadCommit: &headCommit{
+				Author: &commitAuthor{Email: &email},
+			},
+		},
+		Sender: &github.User{
+			Login: &user,
+		},
+	}
+	evtJSON, _ := json.Marshal(evt)
+	summary := EventSummary{}
+	err := summary.parseCheckSuiteEvent(evtJSON)
+	if err != nil {
+		t.Fatal("failed: parse conclusion: failure", err)
+	}
+	if summary.Title != appName {
+		t.Fatal("failed: Title")
+	}
+	if summary.URL != repoURL+"/commit/abc/checks" {
+		t.Fatal("failed: URL", summary.URL)
+	}
+	if describe(summary) != "CheckSuite failure on feature by: user" {
+		t.Fatal("failed: Description", describe(summary))
+	}
+	// 再実行した人ではなくコミットの作成者へ通知する
+	if len(summary.Recipients) != 1 || summary.Recipients[0] != email {
+		t.Fatal("failed: Recipients", summary.Recipients)
+	}
+}
+
+func TestParseCheckRunEvent(t *testing.T) {
+	completed := "completed"
+	timedOut := "timed_out"
+	neutral := "neutral"
+	repoName := "repo-name"
+	runName := "lint"
+	branch := "feature"
+	htmlURL := "url"
+	user := "user"
+	evt := checkRunEvent{
+		Action: &completed,
+		Repo: &github.Repository{
+			Name: &repoName,
+		},
+		CheckRun: &checkRun{
+			Name:       &runName,
+			Conclusion: &timedOut,
+			HTMLURL:    &htmlURL,
+			CheckSuite: &checkSuite{
+				HeadBranch: &branch,
+			},
+		},
+		Sender: &github.User{
+			Login: &user,
+		},
+	}
+	evtJSON, _ := json.Marshal(evt)
+	summary := EventSummary{}
+	err := summary.parseCheckRunEvent(evtJSON)
+	if err != nil {
+		t.Fatal("failed: parse conclusion: timed_out", err)
+	}
+	if summary.Title != runName {
+		t.Fatal("failed: Title")
+	}
+	if summary.URL != htmlURL {
+		t.Fatal("failed: URL")
+	}
+	if describe(summary) != "CheckRun timed_out on feature by: user" {
+		t.Fatal("failed: Description", describe(summary))
+	}
+
+	if len(summary.Recipients) != 0 {
+		t.Fatal("failed: Recipients", summary.Recipients)
+	}
+
+	evt.CheckRun.Conclusion = &neutral
+	evtJSON, _ = json.Marshal(evt)
+	summary = EventSummary{}
+	summary.parseCheckRunEvent(evtJSON)
+	store, _ := NewStore("")
+	if _, err := store.RecordCI(summary, time.Now()); err != ErrIgnoredEvent {
+		t.Fatal("failed: neutral must be ignored", err)
+	}
+}
+
+func TestStoreRecordCI(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gosla2")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "store.json")
+	store, _ := NewStore(file)
+	now := time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	summary := func(fullName string, conclusion string, sha string) EventSummary {
+		payload := `{
+			"action": "completed",
+			"repository": {"name": "api", "full_name": "` + fullName + `"},
+			"workflow_run": {"name": "CI", "head_branch": "main", "head_sha": "` + sha + `", "conclusion": "` + conclusion + `"}
+		}`
+		s := EventSummary{}
+		s.parseWorkflowRunEvent([]byte(payload))
+		return s
+	}
+	type fromTo struct {
+		summary EventSummary
+		state   string
+		err     error
+	}
+	list := []fromTo{
+		fromTo{summary: summary("orgA/api", "success", "a1"), err: ErrIgnoredEvent},
+		fromTo{summary: summary("orgA/api", "failure", "a2"), state: "failure"},
+		// キャンセルやスキップは直前の失敗を上書きしない
+		fromTo{summary: summary("orgA/api", "cancelled", "a3"), err: ErrIgnoredEvent},
+		fromTo{summary: summary("orgA/api", "skipped", "a3"), err: ErrIgnoredEvent},
+		// 同名の別リポジトリの失敗は引き継がない
+		fromTo{summary: summary("orgB/api", "success", "b1"), err: ErrIgnoredEvent},
+		fromTo{summary: summary("orgA/api", "success", "a4"), state: "fixed"},
+		fromTo{summary: summary("orgA/api", "success", "a5"), err: ErrIgnoredEvent},
+	}
+	for _, ft := range list {
+		result, err := store.RecordCI(ft.summary, now)
+		if err != ft.err || (err == nil && result.State != ft.state) {
+			t.Fatal("failed: record CI", ft.summary.RepositoryFullName, ft.summary.State, result.State, err)
+		}
+	}
+
+	// 再起動後も直前の結果を引き継ぐ
+	reloaded, _ := NewStore(file)
+	reloaded.RecordCI(summary("orgA/api", "failure", "a6"), now)
+	reloaded, _ = NewStore(file)
+	if result, err := reloaded.RecordCI(summary("orgA/api", "success", "a7"), now); err != nil || result.State != "fixed" {
+		t.Fatal("failed: reload history", result.State, err)
+	}
+}
+
+func TestStoreRecordCIDedupe(t *testing.T) {
+	store, _ := NewStore("")
+	now := time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	// 同じ実行の workflow_run, check_suite, check_run は一度だけ通知する
+	run := `{"action": "completed", "repository": {"name": "api", "full_name": "org/api"},
+		"workflow_run": {"name": "CI", "head_branch": "main", "head_sha": "abc", "conclusion": "failure", "check_suite_id": 1}}`
+	suite := `{"action": "completed", "repository": {"name": "api", "full_name": "org/api"},
+		"check_suite": {"id": 1, "head_branch": "main", "head_sha": "abc", "conclusion": "failure", "app": {"name": "GitHub Actions"}}}`
+	check := `{"action": "completed", "repository": {"name": "api", "full_name": "org/api"},
+		"check_run": {"name": "%v", "head_sha": "abc", "conclusion": "failure", "check_suite": {"id": 1, "head_branch": "main"}}}`
+	summaries := []EventSummary{}
+	s := EventSummary{}
+	s.parseWorkflowRunEvent([]byte(run))
+	summaries = append(summaries, s)
+	s = EventSummary{}
+	s.parseCheckSuiteEvent([]byte(suite))
+	summaries = append(summaries, s)
+	for _, name := range []string{"lint", "test"} {
+		s = EventSummary{}
+		s.parseCheckRunEvent([]byte(fmt.Sprintf(check, name)))
+		summaries = append(summaries, s)
+	}
+	notified := 0
+	for _, summary := range summaries {
+		if _, err := store.RecordCI(summary, now); err == nil {
+			notified++
+		}
+	}
+	if notified != 1 {
+		t.Fatal("failed: dedupe same run", notified)
+	}
+
+	// 同じコミットでも別のワークフローの失敗はそれぞれ通知する
+	deploy := `{"action": "completed", "repository": {"name": "api", "full_name": "org/api"},
+		"workflow_run": {"name": "%v", "head_branch": "main", "head_sha": "abc", "conclusion": "failure"%v}}`
+	for _, args := range [][]interface{}{{"deploy", `, "check_suite_id": 2`}, {"lint", ""}, {"release", ""}} {
+		s = EventSummary{}
+		s.parseWorkflowRunEvent([]byte(fmt.Sprintf(deploy, args...)))
+		if _, err := store.RecordCI(s, now); err != nil {
+			t.Fatal("failed: other workflow", args[0], err)
+		}
+	}
+
+	// 覚えておく期間を過ぎたら再び通知する
+	if _, err := store.RecordCI(summaries[0], now.Add(ciNotifiedTTL+time.Minute)); err != nil {
+		t.Fatal("failed: notify after TTL", err)
+	}
+}
+
+func TestFindRecipientAccountsByEmail(t *testing.T) {
+	conf := Config{Accounts: map[string]Account{
+		"@a": Account{ID: "@U1", Email: "Author@example.com"},
+		"@b": Account{ID: "@U2"},
+	}}
+	accounts := FindRecipientAccounts([]string{"author@example.com", "@b", "@unknown"}, conf)
+	if len(accounts) != 2 || accounts["@a"].ID != "@U1" || accounts["@b"].ID != "@U2" {
+		t.Fatal("failed: recipients", accounts)
+	}
+}
@@ -0,0 +1,107 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/go-github/github"
+)
+
+func init() {
+	RegisterEventParser(summaryParser{"commit_comment", []string{"created"}, (*EventSummary).parseCommitCommentEvent})
+}
+
+// commitCommentEvent commit_commentイベントのペイロード
+type commitCommentEvent struct {
+	Action  *string            `json:"action,omitempty"`
+	Comment *repositoryComment `json:"comment,omitempty"`
+	Repo    *github.Repository `json:"repository,omitempty"`
+	Sender  *github.User       `json:"sender,omitempty"`
+}
+
+// repositoryComment コミットコメント
+// go-githubの定義には行番号がないので追加する
+type repositoryComment struct {
+	github.RepositoryComment
+	Line *int `json:"line,omitempty"`
+}
+
+// fetchCommitMessage コミットメッセージをAPIから取得する
+func fetchCommitMessage(repository string, sha string, conf Config) (string, error) {
+	token, err := githubToken(repository, conf)
+	if err != nil {
+		return "", err
+	}
+	b, err := githubRequest("GET", fmt.Sprintf("/repos/%v/git/commits/%v", repository, sha), "token "+token, nil)
+	if err != nil {
+		return "", err
+	}
+	commit := struct {
+		Message string `json:"message"`
+	}{}
+	err = json.Unmarshal(b, &commit)
+	if err != nil {
+		return "", err
+	}
+	return commit.Message, nil
+}
+
+// AddCommitMessage コミットコメントのタイトルにコミットメッセージの1行目を加える
+// ペイロードにはコミットメッセージが含まれないので、設定のトークンかGithub AppでAPIから取得する
+// 取得できなくても通知自体は行うので、エラーは呼び出し側で記録するだけでよい
+func AddCommitMessage(summary EventSummary, conf Config) (EventSummary, error) {
+	if summary.Kind != "commit_comment" || summary.CommitID == "" || summary.RepositoryFullName == "" {
+		return summary, nil
+	}
+	message, err := fetchCommitMessage(summary.RepositoryFullName, summary.CommitID, conf)
+	if err != nil {
+		return summary, err
+	}
+	if message != "" {
+		summary.Title = fmt.Sprintf("%v %v", shortSHA(summary.CommitID), firstLine(message))
+	}
+	return summary, nil
+}
+
+// shortSHA 表示用に短縮したコミットSHA
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// parseCommitCommentEvent commit_commentイベントをパースする
+func (summary *EventSummary) parseCommitCommentEvent(payload []byte) error {
+	evt := commitCommentEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	if stringValue(evt.Action) != "created" {
+		return ErrUnhandledAction
+	}
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	if evt.Comment == nil {
+		return missingField("comment")
+	}
+	sha := evt.Comment.GetCommitID()
+	if sha == "" {
+		return missingField("comment.commit_id")
+	}
+	summary.setRepository(evt.Repo)
+	summary.Action = stringValue(evt.Action)
+	summary.Kind = "commit_comment"
+	summary.Actor = actorOf(evt.Comment.GetUser())
+	summary.CommitID = sha
+	summary.Path = evt.Comment.GetPath()
+	summary.Line = intValue(evt.Comment.Line)
+	summary.CreatedAt = evt.Comment.GetCreatedAt()
+	// コミットメッセージは AddCommitMessage で加える
+	summary.Title = shortSHA(sha)
+	summary.URL = evt.Comment.GetHTMLURL()
+	summary.Comment = evt.Comment.GetBody()
+	return nil
+}
@@ -0,0 +1,122 @@
+package lib
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestParseCommitCommentEvent(t *testing.T) {
+	created := "created"
+	other := "other"
+	repoName := "repo-name"
+	repoURL := "https://github.com/owner/repo-name"
+	owner := "owner"
+	sha := "0123456789abcdef"
+	htmlURL := "url"
+	user := "user"
+	body := "body"
+	path := "lib/a.go"
+	line := 12
+	evt := commitCommentEvent{
+		Action: &created,
+		Repo: &github.Repository{
+			Name:    &repoName,
+			HTMLURL: &repoURL,
+			Owner: &github.User{
+				Login: &owner,
+			},
+		},
+		Comment: &repositoryComment{
+			RepositoryComment: github.RepositoryComment{
+				HTMLURL:  &htmlURL,
+				CommitID: &sha,
+				User: &github.User{
+					Login: &user,
+				},
+				Body: &body,
+			},
+		},
+	}
+	evtJSON, _ := json.Marshal(evt)
+	summary := EventSummary{}
+	err := summary.parseCommitCommentEvent(evtJSON)
+	if err != nil {
+		t.Fatal("failed: parse action: created", err)
+	}
+	if summary.RepositoryName != repoName {
+		t.Fatal("failed: RepositoryName")
+	}
+	// パーサはAPIを呼ばない
+	if summary.Title != "0123456" {
+		t.Fatal("failed: Title", summary.Title)
+	}
+	if summary.URL != htmlURL {
+		t.Fatal("failed: URL")
+	}
+	if describe(summary) != "Commit comment created by: user" {
+		t.Fatal("failed: Description", describe(summary))
+	}
+	if summary.Comment != body {
+		t.Fatal("failed: Comment")
+	}
+
+	evt.Comment.Path = &path
+	evt.Comment.Line = &line
+	evtJSON, _ = json.Marshal(evt)
+	summary = EventSummary{}
+	err = summary.parseCommitCommentEvent(evtJSON)
+	if err != nil {
+		t.Fatal("failed: parse positional comment", err)
+	}
+	expected := "Commit comment created by: user on <" + repoURL + "/blob/0123456789abcdef/lib/a.go#L12|lib/a.go:12>"
+	if describe(summary) != expected {
+		t.Fatal("failed: Description", describe(summary))
+	}
+
+	evt.Action = &other
+	evtJSON, _ = json.Marshal(evt)
+	summary = EventSummary{}
+	err = summary.parseCommitCommentEvent(evtJSON)
+	if err != ErrUnhandledAction {
+		t.Fatal("failed: unexpected error")
+	}
+}
+
+func TestAddCommitMessage(t *testing.T) {
+	paths := []string{}
+	defer githubStub(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.Header.Get("Authorization") != "token ghp_token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"sha": "0123456789abcdef", "message": "fix bug\n\ndetail"}`))
+	})()
+
+	summary := EventSummary{Kind: "commit_comment", RepositoryFullName: "owner/repo-name", CommitID: "0123456789abcdef", Title: "0123456"}
+	result, err := AddCommitMessage(summary, Config{GithubToken: "ghp_token"})
+	if err != nil || result.Title != "0123456 fix bug" {
+		t.Fatal("failed: add commit message", result.Title, err)
+	}
+	if len(paths) != 1 || paths[0] != "/repos/owner/repo-name/git/commits/0123456789abcdef" {
+		t.Fatal("failed: request path", paths)
+	}
+
+	// 取得できなくてもタイトルはそのまま
+	result, err = AddCommitMessage(summary, Config{GithubToken: "invalid"})
+	if err == nil || result.Title != "0123456" {
+		t.Fatal("failed: api error", result.Title, err)
+	}
+	// 認証情報がなければAPIを呼ばない
+	result, err = AddCommitMessage(summary, Config{})
+	if err != ErrNoGithubCredential || result.Title != "0123456" || len(paths) != 2 {
+		t.Fatal("failed: no credential", result.Title, err, paths)
+	}
+	// コミットコメント以外は何もしない
+	if _, err := AddCommitMessage(EventSummary{Kind: "issue"}, Config{}); err != nil {
+		t.Fatal("failed: other kind", err)
+	}
+}
@@ -0,0 +1,103 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+func init() {
+	RegisterEventParser(summaryParser{"deployment_status", []string{"created"}, (*EventSummary).parseDeploymentStatusEvent})
+}
+
+// shaPattern デプロイのrefに指定されたコミットSHA (短縮形を含む)
+var shaPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// deploymentStatusEvent deployment_statusイベントのペイロード
+// 依存しているgo-githubの定義にはログURLなどがないので追加する
+type deploymentStatusEvent struct {
+	github.DeploymentStatusEvent
+	DeploymentStatus *deploymentStatus `json:"deployment_status,omitempty"`
+}
+
+// deploymentStatus デプロイ状況
+type deploymentStatus struct {
+	github.DeploymentStatus
+	Environment    *string `json:"environment,omitempty"`
+	LogURL         *string `json:"log_url,omitempty"`
+	EnvironmentURL *string `json:"environment_url,omitempty"`
+}
+
+// isFailedDeployment 失敗扱いとするデプロイ状況かどうか
+func isFailedDeployment(state string) bool {
+	return state == "failure" || state == "error"
+}
+
+// parseDeploymentStatusEvent deployment_statusイベントをパースする
+func (summary *EventSummary) parseDeploymentStatusEvent(payload []byte) error {
+	evt := deploymentStatusEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	deployment := evt.Deployment
+	if deployment == nil {
+		return missingField("deployment")
+	}
+	status := evt.DeploymentStatus
+	if status == nil {
+		return missingField("deployment_status")
+	}
+	environment := deployment.GetEnvironment()
+	if status.Environment != nil {
+		environment = *status.Environment
+	}
+	creator := deployment.GetCreator().GetLogin()
+	state := status.GetState()
+	summary.setRepository(evt.Repo)
+	summary.Kind = "deployment"
+	summary.Actor = actorOf(deployment.GetCreator())
+	summary.State = state
+	summary.Environment = environment
+	summary.CreatedAt = status.GetCreatedAt().Time
+	summary.Title = fmt.Sprintf("Deploy %v to %v", deployment.GetRef(), environment)
+	// ログを優先し、なければ旧来のtarget_url、最後にデプロイ先を使う
+	switch {
+	case stringValue(status.LogURL) != "":
+		summary.URL = *status.LogURL
+	case status.GetTargetURL() != "":
+		summary.URL = status.GetTargetURL()
+	default:
+		summary.URL = stringValue(status.EnvironmentURL)
+	}
+	summary.Comment = status.GetDescription()
+	if environmentURL := stringValue(status.EnvironmentURL); environmentURL != "" {
+		summary.Comment = strings.TrimLeft(fmt.Sprintf("%v\n*Environment:* %v", summary.Comment, environmentURL), "\n")
+	}
+	summary.RefType, summary.Ref = deploymentRef(deployment.GetRef(), deployment.GetSHA())
+	// 失敗時のみデプロイした本人へ通知する
+	if isFailedDeployment(state) && creator != "" {
+		summary.Recipients = []string{"@" + creator}
+	}
+	return nil
+}
+
+// deploymentRef デプロイしたブランチやタグ
+// ペイロードに種類がないので "refs/tags/" で始まるものをタグ、コミットSHAは種類なし、それ以外はブランチとする
+func deploymentRef(ref string, sha string) (string, string) {
+	switch {
+	case strings.HasPrefix(ref, "refs/tags/"):
+		return "tag", strings.TrimPrefix(ref, "refs/tags/")
+	case strings.HasPrefix(ref, "refs/heads/"):
+		return "branch", strings.TrimPrefix(ref, "refs/heads/")
+	case ref == "" || ref == sha || shaPattern.MatchString(ref):
+		return "", ref
+	}
+	return "branch", ref
+}
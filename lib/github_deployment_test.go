@@ -0,0 +1,119 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDeploymentStatusEvent(t *testing.T) {
+	payload := `{
+		"action": "created",
+		"deployment": {"ref": "main", "environment": "production", "creator": {"login": "user"}},
+		"deployment_status": {
+			"state": "failure",
+			"description": "build failed",
+			"environment": "production",
+			"log_url": "log-url",
+			"environment_url": "env-url"
+		},
+		"repository": {"name": "repo-name"}
+	}`
+	summary := EventSummary{}
+	err := summary.parseDeploymentStatusEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse state: failure", err)
+	}
+	if summary.RepositoryName != "repo-name" {
+		t.Fatal("failed: RepositoryName")
+	}
+	if summary.Title != "Deploy main to production" {
+		t.Fatal("failed: Title", summary.Title)
+	}
+	if summary.URL != "log-url" {
+		t.Fatal("failed: URL", summary.URL)
+	}
+	if describe(summary) != "Deployment failure on production by: user" {
+		t.Fatal("failed: Description", describe(summary))
+	}
+	if summary.Comment != "build failed\n*Environment:* env-url" {
+		t.Fatal("failed: Comment", summary.Comment)
+	}
+	if len(summary.Recipients) != 1 || summary.Recipients[0] != "@user" {
+		t.Fatal("failed: Recipients", summary.Recipients)
+	}
+
+	payload = `{
+		"action": "created",
+		"deployment": {"ref": "main", "environment": "staging", "creator": {"login": "user"}},
+		"deployment_status": {"state": "success", "target_url": "target-url"},
+		"repository": {"name": "repo-name"}
+	}`
+	summary = EventSummary{}
+	err = summary.parseDeploymentStatusEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse state: success", err)
+	}
+	if summary.URL != "target-url" {
+		t.Fatal("failed: URL", summary.URL)
+	}
+	if describe(summary) != "Deployment success on staging by: user" {
+		t.Fatal("failed: Description", describe(summary))
+	}
+	if len(summary.Recipients) != 0 {
+		t.Fatal("failed: Recipients", summary.Recipients)
+	}
+}
+
+func TestDeploymentRef(t *testing.T) {
+	type fromTo struct {
+		ref     string
+		refType string
+		to      string
+	}
+	table := []fromTo{
+		{"main", "branch", "main"},
+		{"refs/heads/release/1.0", "branch", "release/1.0"},
+		{"refs/tags/v1.0.0", "tag", "v1.0.0"},
+		{"a1b2c3d", "", "a1b2c3d"},
+		{"sha", "", "sha"},
+	}
+	for _, fromTo := range table {
+		refType, ref := deploymentRef(fromTo.ref, "sha")
+		if refType != fromTo.refType || ref != fromTo.to {
+			t.Fatal("failed: "+fromTo.ref, refType, ref)
+		}
+	}
+}
+
+func TestFindSubscribersDeployment(t *testing.T) {
+	conf := Config{
+		Subscriptions: map[string][]Subscription{
+			"repo-name": []Subscription{
+				{Channel: "deploy", Events: []string{"deployment_status"}, Refs: []string{"branch:main"}},
+			},
+		},
+	}
+	payload := `{
+		"action": "created",
+		"deployment": {"ref": "main", "sha": "0123456789abcdef0123456789abcdef01234567", "environment": "production", "creator": {"login": "user"}},
+		"deployment_status": {"state": "success"},
+		"repository": {"name": "repo-name"}
+	}`
+	summary := EventSummary{Event: "deployment_status"}
+	if err := summary.parseDeploymentStatusEvent([]byte(payload)); err != nil {
+		t.Fatal("failed: parse", err)
+	}
+	if summary.RefType != "branch" || summary.Ref != "main" {
+		t.Fatal("failed: Ref", summary.RefType, summary.Ref)
+	}
+	if _, ok := FindSubscribers(summary, conf)["deploy"]; !ok {
+		t.Fatal("failed: subscribe branch:main")
+	}
+
+	payload = strings.Replace(payload, `"ref": "main"`, `"ref": "feature"`, 1)
+	summary = EventSummary{Event: "deployment_status"}
+	summary.parseDeploymentStatusEvent([]byte(payload))
+	if len(FindSubscribers(summary, conf)) != 0 {
+		t.Fatal("failed: other branch")
+	}
+}
@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+func init() {
+	RegisterEventParser(summaryParser{"discussion", []string{"created", "answered"}, (*EventSummary).parseDiscussionEvent})
+	RegisterEventParser(summaryParser{"discussion_comment", []string{"created", "edited"}, (*EventSummary).parseDiscussionCommentEvent})
+}
+
+// discussionEvent discussionイベントのペイロード
+// 依存しているgo-githubには定義がないので必要な項目だけ用意する
+type discussionEvent struct {
+	Action     *string            `json:"action,omitempty"`
+	Discussion *discussion        `json:"discussion,omitempty"`
+	Answer     *discussionComment `json:"answer,omitempty"`
+	Repo       *github.Repository `json:"repository,omitempty"`
+	Sender     *github.User       `json:"sender,omitempty"`
+}
+
+// discussionCommentEvent discussion_commentイベントのペイロード
+type discussionCommentEvent struct {
+	Action     *string            `json:"action,omitempty"`
+	Discussion *discussion        `json:"discussion,omitempty"`
+	Comment    *discussionComment `json:"comment,omitempty"`
+	Repo       *github.Repository `json:"repository,omitempty"`
+	Sender     *github.User       `json:"sender,omitempty"`
+}
+
+// discussion ディスカッション
+type discussion struct {
+	Number    *int         `json:"number,omitempty"`
+	Title     *string      `json:"title,omitempty"`
+	State     *string      `json:"state,omitempty"`
+	HTMLURL   *string      `json:"html_url,omitempty"`
+	Body      *string      `json:"body,omitempty"`
+	User      *github.User `json:"user,omitempty"`
+	CreatedAt *time.Time   `json:"created_at,omitempty"`
+}
+
+// discussionComment ディスカッションのコメント
+type discussionComment struct {
+	HTMLURL   *string      `json:"html_url,omitempty"`
+	Body      *string      `json:"body,omitempty"`
+	User      *github.User `json:"user,omitempty"`
+	CreatedAt *time.Time   `json:"created_at,omitempty"`
+}
+
+// parseDiscussionEvent discussionイベントをパースする
+func (summary *EventSummary) parseDiscussionEvent(payload []byte) error {
+	evt := discussionEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	action := stringValue(evt.Action)
+	if action != "created" && action != "answered" {
+		return ErrUnhandledAction
+	}
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	if evt.Discussion == nil {
+		return missingField("discussion")
+	}
+	if action == "created" {
+		summary.URL = stringValue(evt.Discussion.HTMLURL)
+		summary.Actor = actorOf(evt.Discussion.User)
+		summary.CreatedAt = timeValue(evt.Discussion.CreatedAt)
+		summary.Comment = stringValue(evt.Discussion.Body)
+	} else {
+		if evt.Answer == nil {
+			return missingField("answer")
+		}
+		// 回答として選ばれたコメントを通知する
+		summary.URL = stringValue(evt.Answer.HTMLURL)
+		summary.Actor = actorOf(evt.Answer.User)
+		summary.CreatedAt = timeValue(evt.Answer.CreatedAt)
+		summary.Comment = stringValue(evt.Answer.Body)
+	}
+	summary.setRepository(evt.Repo)
+	summary.Action = action
+	summary.Kind = "discussion"
+	summary.Number = intValue(evt.Discussion.Number)
+	summary.State = stringValue(evt.Discussion.State)
+	summary.Title = stringValue(evt.Discussion.Title)
+	return nil
+}
+
+// parseDiscussionCommentEvent discussion_commentイベントをパースする
+func (summary *EventSummary) parseDiscussionCommentEvent(payload []byte) error {
+	evt := discussionCommentEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	action := stringValue(evt.Action)
+	if action != "created" && action != "edited" {
+		return ErrUnhandledAction
+	}
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	if evt.Discussion == nil {
+		return missingField("discussion")
+	}
+	if evt.Comment == nil {
+		return missingField("comment")
+	}
+	summary.setRepository(evt.Repo)
+	summary.Action = action
+	summary.Kind = "comment"
+	summary.Actor = actorOf(evt.Comment.User)
+	summary.Number = intValue(evt.Discussion.Number)
+	summary.State = stringValue(evt.Discussion.State)
+	summary.Title = stringValue(evt.Discussion.Title)
+	summary.URL = stringValue(evt.Comment.HTMLURL)
+	summary.CreatedAt = timeValue(evt.Comment.CreatedAt)
+	summary.Comment = stringValue(evt.Comment.Body)
+	return nil
+}
@@ -0,0 +1,156 @@
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestParseDiscussionEvent(t *testing.T) {
+	created := "created"
+	answered := "answered"
+	other := "other"
+	repoName := "repo-name"
+	title := "discussion-title"
+	htmlURL := "url"
+	answerURL := "answer-url"
+	user := "user"
+	answerer := "answerer"
+	body := "body"
+	answerBody := "answer-body"
+	evt := discussionEvent{
+		Action: &created,
+		Repo: &github.Repository{
+			Name: &repoName,
+		},
+		Discussion: &discussion{
+			Title:   &title,
+			HTMLURL: &htmlURL,
+			Body:    &body,
+			User: &github.User{
+				Login: &user,
+			},
+		},
+	}
+	evtJSON, _ := json.Marshal(evt)
+	summary := EventSummary{}
+	err := summary.parseDiscussionEvent(evtJSON)
+	if err != nil {
+		t.Fatal("failed: parse action: created", err)
+	}
+	if summary.RepositoryName != repoName {
+		t.Fatal("failed: RepositoryName")
+	}
+	if summary.Title != title {
+		t.Fatal("failed: Title")
+	}
+	if summary.URL != htmlURL {
+		t.Fatal("failed: URL")
+	}
+	if describe(summary) != "Discussion "+created+" by: "+user {
+		t.Fatal("failed: Description")
+	}
+	if summary.Comment != body {
+		t.Fatal("failed: Comment")
+	}
+
+	evt.Action = &answered
+	evt.Answer = &discussionComment{
+		HTMLURL: &answerURL,
+		Body:    &answerBody,
+		User: &github.User{
+			Login: &answerer,
+		},
+	}
+	evtJSON, _ = json.Marshal(evt)
+	summary = EventSummary{}
+	err = summary.parseDiscussionEvent(evtJSON)
+	if err != nil {
+		t.Fatal("failed: parse action: answered", err)
+	}
+	if summary.URL != answerURL {
+		t.Fatal("failed: URL")
+	}
+	if describe(summary) != "Discussion "+answered+" by: "+answerer {
+		t.Fatal("failed: Description")
+	}
+	if summary.Comment != answerBody {
+		t.Fatal("failed: Comment")
+	}
+
+	evt.Action = &other
+	evtJSON, _ = json.Marshal(evt)
+	summary = EventSummary{}
+	err = summary.parseDiscussionEvent(evtJSON)
+	if err != ErrUnhandledAction {
+		t.Fatal("failed: unexpected error")
+	}
+}
+
+func TestParseDiscussionCommentEvent(t *testing.T) {
+	created := "created"
+	edited := "edited"
+	other := "other"
+	repoName := "repo-name"
+	title := "discussion-title"
+	htmlURL := "url"
+	user := "user"
+	body := "body"
+	evt := discussionCommentEvent{
+		Action: &created,
+		Repo: &github.Repository{
+			Name: &repoName,
+		},
+		Discussion: &discussion{
+			Title: &title,
+		},
+		Comment: &discussionComment{
+			HTMLURL: &htmlURL,
+			Body:    &body,
+			User: &github.User{
+				Login: &user,
+			},
+		},
+	}
+	evtJSON, _ := json.Marshal(evt)
+	summary := EventSummary{}
+	err := summary.parseDiscussionCommentEvent(evtJSON)
+	if err != nil {
+		t.Fatal("failed: parse action: created", err)
+	}
+	if summary.RepositoryName != repoName {
+		t.Fatal("failed: RepositoryName")
+	}
+	if summary.Title != title {
+		t.Fatal("failed: Title")
+	}
+	if summary.URL != htmlURL {
+		t.Fatal("failed: URL")
+	}
+	if describe(summary) != "Comment "+created+" by: "+user {
+		t.Fatal("failed: Description")
+	}
+	if summary.Comment != body {
+		t.Fatal("failed: Comment")
+	}
+
+	evt.Action = &edited
+	evtJSON, _ = json.Marshal(evt)
+	summary = EventSummary{}
+	err = summary.parseDiscussionCommentEvent(evtJSON)
+	if err != nil {
+		t.Fatal("failed: parse action: edited")
+	}
+	if describe(summary) != "Comment "+edited+" by: "+user {
+		t.Fatal("failed: Description")
+	}
+
+	evt.Action = &other
+	evtJSON, _ = json.Marshal(evt)
+	summary = EventSummary{}
+	err = summary.parseDiscussionCommentEvent(evtJSON)
+	if err != ErrUnhandledAction {
+		t.Fatal("failed: unexpected error")
+	}
+}
@@ -0,0 +1,52 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/go-github/github"
+)
+
+func init() {
+	RegisterEventParser(summaryParser{"milestone", []string{"created", "closed", "edited"}, (*EventSummary).parseMilestoneEvent})
+}
+
+// parseMilestoneEvent milestoneイベントをパースする
+// 個人宛ではなくチャンネル購読での通知を想定している
+func (summary *EventSummary) parseMilestoneEvent(payload []byte) error {
+	evt := github.MilestoneEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	action := evt.GetAction()
+	if action != "created" && action != "closed" && action != "edited" {
+		return ErrUnhandledAction
+	}
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	milestone := evt.Milestone
+	if milestone == nil {
+		return missingField("milestone")
+	}
+	summary.setRepository(evt.Repo)
+	summary.Action = action
+	summary.Kind = "milestone"
+	summary.ChannelOnly = true
+	summary.Actor = actorOf(evt.GetSender())
+	summary.Number = milestone.GetNumber()
+	summary.State = milestone.GetState()
+	summary.Title = milestone.GetTitle()
+	summary.URL = milestone.GetHTMLURL()
+	summary.CreatedAt = milestone.GetCreatedAt()
+	due := "none"
+	if milestone.DueOn != nil {
+		due = milestone.DueOn.Format("2006-01-02")
+	}
+	summary.Comment = fmt.Sprintf("*Due:* %v\n*Issues:* %v open / %v closed", due, milestone.GetOpenIssues(), milestone.GetClosedIssues())
+	if action == "closed" && milestone.GetOpenIssues() > 0 {
+		summary.Comment = fmt.Sprintf("%v\n:warning: closed with %v open issues left", summary.Comment, milestone.GetOpenIssues())
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestParseMilestoneEvent(t *testing.T) {
+	payload := `{
+		"action": "closed",
+		"milestone": {
+			"title": "Sprint 1",
+			"html_url": "url",
+			"open_issues": 2,
+			"closed_issues": 8,
+			"due_on": "2018-07-01T07:00:00Z"
+		},
+		"repository": {"name": "repo-name"},
+		"sender": {"login": "user"}
+	}`
+	summary := EventSummary{}
+	err := summary.parseMilestoneEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse action: closed", err)
+	}
+	if summary.RepositoryName != "repo-name" {
+		t.Fatal("failed: RepositoryName")
+	}
+	if summary.Title != "Sprint 1" {
+		t.Fatal("failed: Title")
+	}
+	if summary.URL != "url" {
+		t.Fatal("failed: URL")
+	}
+	if describe(summary) != "Milestone closed by: user" {
+		t.Fatal("failed: Description", describe(summary))
+	}
+	if summary.Comment != "*Due:* 2018-07-01\n*Issues:* 2 open / 8 closed\n:warning: closed with 2 open issues left" {
+		t.Fatal("failed: Comment", summary.Comment)
+	}
+
+	payload = `{
+		"action": "created",
+		"milestone": {"title": "Sprint 2", "html_url": "url"},
+		"repository": {"name": "repo-name"},
+		"sender": {"login": "user"}
+	}`
+	summary = EventSummary{}
+	err = summary.parseMilestoneEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse action: created", err)
+	}
+	if summary.Comment != "*Due:* none\n*Issues:* 0 open / 0 closed" {
+		t.Fatal("failed: Comment", summary.Comment)
+	}
+
+	summary = EventSummary{}
+	err = summary.parseMilestoneEvent([]byte(`{"action": "deleted"}`))
+	if err != ErrUnhandledAction {
+		t.Fatal("failed: unexpected error")
+	}
+}
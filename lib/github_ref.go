@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+func init() {
+	RegisterEventParser(summaryParser{"create", nil, (*EventSummary).parseCreateEvent})
+	RegisterEventParser(summaryParser{"delete", nil, (*EventSummary).parseDeleteEvent})
+}
+
+// refURL ブランチやタグのURL
+func refURL(repoURL string, refType string, ref string) string {
+	if refType == "tag" {
+		return fmt.Sprintf("%v/releases/tag/%v", repoURL, ref)
+	}
+	return fmt.Sprintf("%v/tree/%v", repoURL, ref)
+}
+
+// matchRef ブランチやタグがパターンに一致するかどうか
+// パターンは "branch:release/*" や "tag:v*" のように種類を前置でき、省略時は種類を問わない
+func matchRef(pattern string, refType string, ref string) bool {
+	if i := strings.Index(pattern, ":"); i >= 0 {
+		if pattern[:i] != refType {
+			return false
+		}
+		pattern = pattern[i+1:]
+	}
+	ok, _ := path.Match(pattern, ref)
+	return ok
+}
+
+// parseCreateEvent createイベントをパースする
+func (summary *EventSummary) parseCreateEvent(payload []byte) error {
+	evt := github.CreateEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	refType := evt.GetRefType()
+	if refType != "branch" && refType != "tag" {
+		return ErrUnhandledAction
+	}
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	summary.setRepository(evt.Repo)
+	// createイベントはアクションを持たないので種類と合わせて補う
+	summary.Action = "created"
+	summary.Kind = refType
+	summary.Actor = actorOf(evt.GetSender())
+	summary.Title = fmt.Sprintf("%v %v created", refType, evt.GetRef())
+	summary.URL = refURL(evt.Repo.GetHTMLURL(), refType, evt.GetRef())
+	summary.Ref = evt.GetRef()
+	summary.RefType = refType
+	return nil
+}
+
+// parseDeleteEvent deleteイベントをパースする
+func (summary *EventSummary) parseDeleteEvent(payload []byte) error {
+	evt := github.DeleteEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	refType := evt.GetRefType()
+	if refType != "branch" && refType != "tag" {
+		return ErrUnhandledAction
+	}
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	summary.setRepository(evt.Repo)
+	summary.Action = "deleted"
+	summary.Kind = refType
+	summary.Actor = actorOf(evt.GetSender())
+	summary.Title = fmt.Sprintf("%v %v deleted", refType, evt.GetRef())
+	summary.URL = evt.Repo.GetHTMLURL()
+	summary.Ref = evt.GetRef()
+	summary.RefType = refType
+	return nil
+}
@@ -0,0 +1,88 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestMatchRef(t *testing.T) {
+	type args struct {
+		pattern string
+		refType string
+		ref     string
+	}
+	table := map[args]bool{
+		args{"release/*", "branch", "release/1.0"}:        true,
+		args{"release/*", "tag", "release/1.0"}:           true,
+		args{"branch:release/*", "branch", "release/1.0"}: true,
+		args{"branch:release/*", "tag", "release/1.0"}:    false,
+		args{"tag:v*", "tag", "v1.0.0"}:                   true,
+		args{"tag:v*", "branch", "v1.0.0"}:                false,
+		args{"release/*", "branch", "feature/a"}:          false,
+	}
+	for args, expected := range table {
+		if matchRef(args.pattern, args.refType, args.ref) != expected {
+			t.Fatal("failed: "+args.pattern+" "+args.refType+" "+args.ref, expected)
+		}
+	}
+}
+
+func TestParseCreateEvent(t *testing.T) {
+	payload := `{
+		"ref": "v1.0.0",
+		"ref_type": "tag",
+		"repository": {"name": "repo-name", "html_url": "repo-url"},
+		"sender": {"login": "user"}
+	}`
+	summary := EventSummary{}
+	err := summary.parseCreateEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse ref_type: tag", err)
+	}
+	if summary.RepositoryName != "repo-name" {
+		t.Fatal("failed: RepositoryName")
+	}
+	if summary.Title != "tag v1.0.0 created" {
+		t.Fatal("failed: Title", summary.Title)
+	}
+	if summary.URL != "repo-url/releases/tag/v1.0.0" {
+		t.Fatal("failed: URL", summary.URL)
+	}
+	if describe(summary) != "Tag created by: user" {
+		t.Fatal("failed: Description", describe(summary))
+	}
+	if summary.Ref != "v1.0.0" || summary.RefType != "tag" {
+		t.Fatal("failed: Ref", summary.Ref, summary.RefType)
+	}
+
+	summary = EventSummary{}
+	err = summary.parseCreateEvent([]byte(`{"ref": "repo", "ref_type": "repository"}`))
+	if err != ErrUnhandledAction {
+		t.Fatal("failed: unexpected error")
+	}
+}
+
+func TestParseDeleteEvent(t *testing.T) {
+	payload := `{
+		"ref": "release/1.0",
+		"ref_type": "branch",
+		"repository": {"name": "repo-name", "html_url": "repo-url"},
+		"sender": {"login": "user"}
+	}`
+	summary := EventSummary{}
+	err := summary.parseDeleteEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse ref_type: branch", err)
+	}
+	if summary.Title != "branch release/1.0 deleted" {
+		t.Fatal("failed: Title", summary.Title)
+	}
+	if summary.URL != "repo-url" {
+		t.Fatal("failed: URL", summary.URL)
+	}
+	if describe(summary) != "Branch deleted by: user" {
+		t.Fatal("failed: Description", describe(summary))
+	}
+	if summary.Ref != "release/1.0" || summary.RefType != "branch" {
+		t.Fatal("failed: Ref", summary.Ref, summary.RefType)
+	}
+}
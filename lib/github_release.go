@@ -0,0 +1,53 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/go-github/github"
+)
+
+func init() {
+	RegisterEventParser(summaryParser{"release", []string{"published"}, (*EventSummary).parseReleaseEvent})
+}
+
+// parseReleaseEvent releaseイベントをパースする
+func (summary *EventSummary) parseReleaseEvent(payload []byte) error {
+	evt := github.ReleaseEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	if evt.GetAction() != "published" {
+		return ErrUnhandledAction
+	}
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	release := evt.Release
+	if release == nil {
+		return missingField("release")
+	}
+	summary.setRepository(evt.Repo)
+	summary.Action = evt.GetAction()
+	summary.Kind = "release"
+	summary.ChannelOnly = true
+	summary.Actor = actorOf(release.GetAuthor())
+	summary.Ref = release.GetTagName()
+	summary.RefType = "tag"
+	summary.CreatedAt = release.GetPublishedAt().Time
+	summary.Title = release.GetName()
+	if summary.Title == "" {
+		summary.Title = release.GetTagName()
+	}
+	summary.URL = release.GetHTMLURL()
+	// 本文と同じくMarkdownで組み立て、mrkdwnへの変換はレンダラに任せる
+	summary.Comment = release.GetBody()
+	if len(release.Assets) > 0 {
+		summary.Comment = fmt.Sprintf("%v\n\n**Assets**", summary.Comment)
+		for _, asset := range release.Assets {
+			summary.Comment = fmt.Sprintf("%v\n- [%v](%v)", summary.Comment, asset.GetName(), asset.GetBrowserDownloadURL())
+		}
+	}
+	return nil
+}
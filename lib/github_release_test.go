@@ -0,0 +1,96 @@
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestParseReleaseEvent(t *testing.T) {
+	published := "published"
+	other := "other"
+	repoName := "repo-name"
+	tag := "v1.0.0"
+	name := "First release"
+	htmlURL := "url"
+	user := "user"
+	body := "## Changes\n- **new** feature"
+	assetName := "gosla2.zip"
+	assetURL := "asset-url"
+	evt := github.ReleaseEvent{
+		Action: &published,
+		Repo: &github.Repository{
+			Name: &repoName,
+		},
+		Release: &github.RepositoryRelease{
+			TagName: &tag,
+			Name:    &name,
+			HTMLURL: &htmlURL,
+			Body:    &body,
+			Author: &github.User{
+				Login: &user,
+			},
+			Assets: []github.ReleaseAsset{
+				{
+					Name:               &assetName,
+					BrowserDownloadURL: &assetURL,
+				},
+			},
+		},
+	}
+	evtJSON, _ := json.Marshal(evt)
+	summary := EventSummary{}
+	err := summary.parseReleaseEvent(evtJSON)
+	if err != nil {
+		t.Fatal("failed: parse action: published", err)
+	}
+	if summary.RepositoryName != repoName {
+		t.Fatal("failed: RepositoryName")
+	}
+	if summary.Title != name {
+		t.Fatal("failed: Title")
+	}
+	if summary.URL != htmlURL {
+		t.Fatal("failed: URL")
+	}
+	if describe(summary) != "Release v1.0.0 published by: user" {
+		t.Fatal("failed: Description", describe(summary))
+	}
+	if toSlackMarkdown(summary.Comment) != "*Changes*\n• *new* feature\n\n*Assets*\n• <asset-url|gosla2.zip>" {
+		t.Fatal("failed: Comment", summary.Comment)
+	}
+
+	if !summary.ChannelOnly {
+		t.Fatal("failed: ChannelOnly")
+	}
+	conf := Config{Accounts: map[string]Account{"@user": Account{ID: "@user"}}}
+	summary.Comment = "Thanks @user"
+	if len(FindMentionedAccounts(summary, conf)) != 0 {
+		t.Fatal("failed: FindMentionedAccounts for release")
+	}
+	summary.ChannelOnly = false
+	if len(FindMentionedAccounts(summary, conf)) != 1 {
+		t.Fatal("failed: FindMentionedAccounts")
+	}
+
+	evt.Release.Name = nil
+	evt.Release.Assets = nil
+	evtJSON, _ = json.Marshal(evt)
+	summary = EventSummary{}
+	err = summary.parseReleaseEvent(evtJSON)
+	if err != nil {
+		t.Fatal("failed: parse without name", err)
+	}
+	if summary.Title != tag {
+		t.Fatal("failed: Title")
+	}
+
+	evt.Action = &other
+	evtJSON, _ = json.Marshal(evt)
+	summary = EventSummary{}
+	err = summary.parseReleaseEvent(evtJSON)
+	if err != ErrUnhandledAction {
+		t.Fatal("failed: unexpected error")
+	}
+}
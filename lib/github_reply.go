@@ -0,0 +1,223 @@
+package lib
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// githubAPIURL Github REST APIのURL
+var githubAPIURL = "https://api.github.com"
+
+// githubClient Github REST APIのクライアント
+// Webhookの処理中に呼ぶので、応答が遅い場合に待ち続けないようタイムアウトを設ける
+var githubClient = &http.Client{Timeout: 10 * time.Second}
+
+var (
+	// ErrNoGithubCredential Githubへ投稿するためのトークンもGithub Appも設定されていない
+	ErrNoGithubCredential = errors.New("no_github_credential")
+	// ErrInvalidReplySource 返信先の情報が読み取れない
+	ErrInvalidReplySource = errors.New("invalid_reply_source")
+	// ErrInvalidPrivateKey Github Appの秘密鍵が読み取れない
+	ErrInvalidPrivateKey = errors.New("invalid_private_key")
+)
+
+// repositoryPattern REST APIのパスに埋め込める "owner/repo" 形式のリポジトリ名
+var repositoryPattern = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+
+// replySourcePrefix 返信先の情報を埋め込んだ block_id などの接頭辞
+const replySourcePrefix = "gosla_reply:"
+
+// ReplySource Slackから返信する先のIssueやプルリクエスト、レビューのスレッド
+type ReplySource struct {
+	// Repository "owner/repo" 形式のリポジトリ名
+	Repository string
+	Number     int
+	// ReviewThreadID レビューのスレッドに返信する場合の先頭コメントのID
+	ReviewThreadID int64
+}
+
+// ReplySourceOf サマリから返信先を取り出す
+// 返信できないイベントの場合は false
+func ReplySourceOf(summary EventSummary) (ReplySource, bool) {
+	if !threadEvents[summary.Event] || summary.RepositoryFullName == "" || summary.Number == 0 {
+		return ReplySource{}, false
+	}
+	return ReplySource{
+		Repository:     summary.RepositoryFullName,
+		Number:         summary.Number,
+		ReviewThreadID: summary.ReviewThreadID,
+	}, true
+}
+
+// String block_id や private_metadata に埋め込む文字列 ("gosla_reply:owner/repo:12:0")
+func (source ReplySource) String() string {
+	return fmt.Sprintf("%v%v:%v:%v", replySourcePrefix, source.Repository, source.Number, source.ReviewThreadID)
+}
+
+// ParseReplySource 埋め込んだ文字列から返信先を読み取る
+func ParseReplySource(text string) (ReplySource, error) {
+	if !strings.HasPrefix(text, replySourcePrefix) {
+		return ReplySource{}, ErrInvalidReplySource
+	}
+	parts := strings.Split(strings.TrimPrefix(text, replySourcePrefix), ":")
+	if len(parts) != 3 || !validRepository(parts[0]) {
+		return ReplySource{}, ErrInvalidReplySource
+	}
+	number, err := strconv.Atoi(parts[1])
+	if err != nil || number == 0 {
+		return ReplySource{}, ErrInvalidReplySource
+	}
+	threadID, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return ReplySource{}, ErrInvalidReplySource
+	}
+	return ReplySource{Repository: parts[0], Number: number, ReviewThreadID: threadID}, nil
+}
+
+// validRepository パスを辿れる "." や ".." を含まない "owner/repo" 形式か
+func validRepository(repository string) bool {
+	if !repositoryPattern.MatchString(repository) {
+		return false
+	}
+	for _, part := range strings.Split(repository, "/") {
+		if part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// PostReply Githubへ返信を投稿する
+// レビューのスレッドにはスレッドへの返信、それ以外はIssueやプルリクエストへのコメントとして投稿する
+func PostReply(source ReplySource, body string, conf Config) error {
+	token, err := githubToken(source.Repository, conf)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/repos/%v/issues/%v/comments", source.Repository, source.Number)
+	if source.ReviewThreadID != 0 {
+		path = fmt.Sprintf("/repos/%v/pulls/%v/comments/%v/replies", source.Repository, source.Number, source.ReviewThreadID)
+	}
+	_, err = githubRequest("POST", path, "token "+token, map[string]string{"body": body})
+	return err
+}
+
+// githubToken 投稿に使うトークン
+// トークンの設定を優先し、なければGithub Appのインストールトークンを発行する
+func githubToken(repository string, conf Config) (string, error) {
+	if conf.GithubToken != "" {
+		return conf.GithubToken, nil
+	}
+	if conf.GithubAppID == 0 || conf.GithubAppPrivateKeyFile == "" {
+		return "", ErrNoGithubCredential
+	}
+	pemBytes, err := ioutil.ReadFile(conf.GithubAppPrivateKeyFile)
+	if err != nil {
+		return "", err
+	}
+	jwt, err := githubAppJWT(conf.GithubAppID, pemBytes, time.Now())
+	if err != nil {
+		return "", err
+	}
+	installation := struct {
+		ID int64 `json:"id"`
+	}{}
+	b, err := githubRequest("GET", "/repos/"+repository+"/installation", "Bearer "+jwt, nil)
+	if err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(b, &installation); err != nil {
+		return "", err
+	}
+	access := struct {
+		Token string `json:"token"`
+	}{}
+	b, err = githubRequest("POST", fmt.Sprintf("/app/installations/%v/access_tokens", installation.ID), "Bearer "+jwt, nil)
+	if err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(b, &access); err != nil {
+		return "", err
+	}
+	return access.Token, nil
+}
+
+// githubAppJWT Github Appとして認証するためのJWT (RS256) を生成する
+// 時計のずれを考慮して発行時刻は1分前にし、有効期限は上限の10分に収める
+func githubAppJWT(appID int64, pemBytes []byte, now time.Time) (string, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return "", ErrInvalidPrivateKey
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return "", ErrInvalidPrivateKey
+		}
+		var ok bool
+		if key, ok = parsed.(*rsa.PrivateKey); !ok {
+			return "", ErrInvalidPrivateKey
+		}
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": appID,
+	})
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hashed := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// githubRequest Github REST APIを呼び出し、レスポンスの本文を返す
+func githubRequest(method string, path string, authorization string, payload interface{}) ([]byte, error) {
+	var body []byte
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	req, _ := http.NewRequest(method, githubAPIURL+path, bytes.NewReader(body))
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	res, err := githubClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("github_api_error: %v %v", res.Status, string(b))
+	}
+	return b, nil
+}
@@ -0,0 +1,160 @@
+package lib
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// githubStub Github APIの代わりに応答するテスト用サーバ
+func githubStub(handler http.HandlerFunc) func() {
+	server := httptest.NewServer(handler)
+	original := githubAPIURL
+	githubAPIURL = server.URL
+	return func() {
+		server.Close()
+		githubAPIURL = original
+	}
+}
+
+func TestReplySource(t *testing.T) {
+	summary := EventSummary{Event: "pull_request_review_comment", RepositoryName: "repo", RepositoryFullName: "org/repo", Number: 12, ReviewThreadID: 34}
+	source, ok := ReplySourceOf(summary)
+	if !ok || source.String() != "gosla_reply:org/repo:12:34" {
+		t.Fatal("failed: reply source", source)
+	}
+	parsed, err := ParseReplySource(source.String())
+	if err != nil || parsed != source {
+		t.Fatal("failed: parse reply source", parsed, err)
+	}
+	if _, ok := ReplySourceOf(EventSummary{Event: "push", RepositoryFullName: "org/repo", Number: 12}); ok {
+		t.Fatal("failed: unsupported event")
+	}
+	if _, ok := ReplySourceOf(EventSummary{Event: "issues", RepositoryName: "repo", Number: 12}); ok {
+		t.Fatal("failed: no full name")
+	}
+	for _, text := range []string{"", "gosla_notification", "gosla_reply:repo:12:0", "gosla_reply:org/repo:x:0", "gosla_reply:org/repo:12", "gosla_reply:../..:12:0", "gosla_reply:org/..:12:0", "gosla_reply:org/repo?x=1:12:0", "gosla_reply:org/re po:12:0"} {
+		if _, err := ParseReplySource(text); err != ErrInvalidReplySource {
+			t.Fatal("failed: invalid reply source", text, err)
+		}
+	}
+}
+
+func TestPostReply(t *testing.T) {
+	requests := []*http.Request{}
+	bodies := []string{}
+	defer githubStub(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, r)
+		bodies = append(bodies, string(b))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 1}`))
+	})()
+
+	conf := Config{GithubToken: "ghp_token"}
+	if err := PostReply(ReplySource{Repository: "org/repo", Number: 12}, "LGTM", conf); err != nil {
+		t.Fatal("failed: reply to issue", err)
+	}
+	if err := PostReply(ReplySource{Repository: "org/repo", Number: 12, ReviewThreadID: 34}, "LGTM", conf); err != nil {
+		t.Fatal("failed: reply to review thread", err)
+	}
+	if requests[0].URL.Path != "/repos/org/repo/issues/12/comments" || requests[1].URL.Path != "/repos/org/repo/pulls/12/comments/34/replies" {
+		t.Fatal("failed: reply path", requests[0].URL.Path, requests[1].URL.Path)
+	}
+	if requests[0].Header.Get("Authorization") != "token ghp_token" || bodies[0] != `{"body":"LGTM"}` {
+		t.Fatal("failed: reply request", requests[0].Header, bodies[0])
+	}
+	if err := PostReply(ReplySource{Repository: "org/repo", Number: 12}, "LGTM", Config{}); err != ErrNoGithubCredential {
+		t.Fatal("failed: no credential", err)
+	}
+}
+
+func TestPostReplyError(t *testing.T) {
+	defer githubStub(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "Not Found"}`))
+	})()
+	err := PostReply(ReplySource{Repository: "org/repo", Number: 12}, "LGTM", Config{GithubToken: "ghp_token"})
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatal("failed: api error", err)
+	}
+}
+
+func TestPostReplyAsGithubApp(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	dir, _ := ioutil.TempDir("", "gosla2")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "app.pem")
+	ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600)
+
+	authorizations := map[string]string{}
+	defer githubStub(func(w http.ResponseWriter, r *http.Request) {
+		authorizations[r.URL.Path] = r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/repos/org/repo/installation":
+			w.Write([]byte(`{"id": 56}`))
+		case "/app/installations/56/access_tokens":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"token": "ghs_installation"}`))
+		default:
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": 1}`))
+		}
+	})()
+
+	conf := Config{GithubAppID: 78, GithubAppPrivateKeyFile: file}
+	if err := PostReply(ReplySource{Repository: "org/repo", Number: 12}, "LGTM", conf); err != nil {
+		t.Fatal("failed: reply as app", err)
+	}
+	if !strings.HasPrefix(authorizations["/repos/org/repo/installation"], "Bearer ") {
+		t.Fatal("failed: app jwt", authorizations)
+	}
+	if authorizations["/repos/org/repo/issues/12/comments"] != "token ghs_installation" {
+		t.Fatal("failed: installation token", authorizations)
+	}
+}
+
+func TestGithubAppJWT(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	now := time.Unix(1500000000, 0)
+	jwt, err := githubAppJWT(78, pemBytes, now)
+	if err != nil {
+		t.Fatal("failed: jwt", err)
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatal("failed: jwt format", jwt)
+	}
+	header, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	if string(header) != `{"alg":"RS256","typ":"JWT"}` {
+		t.Fatal("failed: jwt header", string(header))
+	}
+	claims := map[string]int64{}
+	b, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	json.Unmarshal(b, &claims)
+	if claims["iss"] != 78 || claims["iat"] != 1499999940 || claims["exp"] != 1500000540 {
+		t.Fatal("failed: jwt claims", claims)
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hashed[:], signature); err != nil {
+		t.Fatal("failed: jwt signature", err)
+	}
+	if _, err := githubAppJWT(78, []byte("invalid"), now); err != ErrInvalidPrivateKey {
+		t.Fatal("failed: invalid key", err)
+	}
+}
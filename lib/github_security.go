@@ -0,0 +1,279 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+func init() {
+	RegisterEventParser(summaryParser{"dependabot_alert", []string{"created", "reopened", "reintroduced"}, (*EventSummary).parseDependabotAlertEvent})
+	RegisterEventParser(summaryParser{"code_scanning_alert", []string{"created", "reopened"}, (*EventSummary).parseCodeScanningAlertEvent})
+	RegisterEventParser(summaryParser{"secret_scanning_alert", []string{"created", "reopened"}, (*EventSummary).parseSecretScanningAlertEvent})
+}
+
+// securityEvents セキュリティアラートとしてオーナーへ通知するイベント
+var securityEvents = map[string]bool{
+	"dependabot_alert":      true,
+	"code_scanning_alert":   true,
+	"secret_scanning_alert": true,
+}
+
+// severityLevels 重要度の比較用の順位
+var severityLevels = map[string]int{
+	"low":      1,
+	"medium":   2,
+	"moderate": 2,
+	"high":     3,
+	"critical": 4,
+}
+
+// ErrInvalidSeverity 最低重要度が low, medium (moderate), high, critical のいずれでもない
+var ErrInvalidSeverity = errors.New("invalid_severity")
+
+// validThreshold 最低重要度として指定できる値か
+// Dependabotの moderate は medium と同じ重要度として受け付ける
+func validThreshold(threshold string) bool {
+	if threshold == "" {
+		return true
+	}
+	_, ok := severityLevels[strings.ToLower(threshold)]
+	return ok
+}
+
+// severityAtLeast 重要度が閾値以上かどうか
+// 閾値が未設定、または重要度を持たないイベントは常に通す
+// 知らない重要度は見落とさないよう critical として扱う
+func severityAtLeast(severity string, threshold string) bool {
+	if threshold == "" || severity == "" {
+		return true
+	}
+	level, ok := severityLevels[strings.ToLower(severity)]
+	if !ok {
+		level = severityLevels["critical"]
+	}
+	return level >= severityLevels[strings.ToLower(threshold)]
+}
+
+// dependabotAlertEvent dependabot_alertイベントのペイロード
+// 依存しているgo-githubには定義がないので必要な項目だけ用意する
+type dependabotAlertEvent struct {
+	Action *string            `json:"action,omitempty"`
+	Alert  *dependabotAlert   `json:"alert,omitempty"`
+	Repo   *github.Repository `json:"repository,omitempty"`
+	Sender *github.User       `json:"sender,omitempty"`
+}
+
+// dependabotAlert Dependabotのアラート
+type dependabotAlert struct {
+	Number     *int    `json:"number,omitempty"`
+	State      *string `json:"state,omitempty"`
+	HTMLURL    *string `json:"html_url,omitempty"`
+	Dependency *struct {
+		Package *struct {
+			Ecosystem *string `json:"ecosystem,omitempty"`
+			Name      *string `json:"name,omitempty"`
+		} `json:"package,omitempty"`
+		ManifestPath *string `json:"manifest_path,omitempty"`
+	} `json:"dependency,omitempty"`
+	SecurityAdvisory *struct {
+		Summary  *string `json:"summary,omitempty"`
+		Severity *string `json:"severity,omitempty"`
+	} `json:"security_advisory,omitempty"`
+}
+
+// codeScanningAlertEvent code_scanning_alertイベントのペイロード
+type codeScanningAlertEvent struct {
+	Action *string            `json:"action,omitempty"`
+	Alert  *codeScanningAlert `json:"alert,omitempty"`
+	Repo   *github.Repository `json:"repository,omitempty"`
+	Sender *github.User       `json:"sender,omitempty"`
+}
+
+// codeScanningAlert コードスキャンのアラート
+type codeScanningAlert struct {
+	Number  *int    `json:"number,omitempty"`
+	State   *string `json:"state,omitempty"`
+	HTMLURL *string `json:"html_url,omitempty"`
+	Rule    *struct {
+		ID                    *string `json:"id,omitempty"`
+		Description           *string `json:"description,omitempty"`
+		Severity              *string `json:"severity,omitempty"`
+		SecuritySeverityLevel *string `json:"security_severity_level,omitempty"`
+	} `json:"rule,omitempty"`
+	Tool *struct {
+		Name *string `json:"name,omitempty"`
+	} `json:"tool,omitempty"`
+	MostRecentInstance *struct {
+		Location *struct {
+			Path      *string `json:"path,omitempty"`
+			StartLine *int    `json:"start_line,omitempty"`
+		} `json:"location,omitempty"`
+	} `json:"most_recent_instance,omitempty"`
+}
+
+// secretScanningAlertEvent secret_scanning_alertイベントのペイロード
+type secretScanningAlertEvent struct {
+	Action *string              `json:"action,omitempty"`
+	Alert  *secretScanningAlert `json:"alert,omitempty"`
+	Repo   *github.Repository   `json:"repository,omitempty"`
+	Sender *github.User         `json:"sender,omitempty"`
+}
+
+// secretScanningAlert シークレットスキャンのアラート
+type secretScanningAlert struct {
+	Number                *int    `json:"number,omitempty"`
+	State                 *string `json:"state,omitempty"`
+	HTMLURL               *string `json:"html_url,omitempty"`
+	SecretType            *string `json:"secret_type,omitempty"`
+	SecretTypeDisplayName *string `json:"secret_type_display_name,omitempty"`
+}
+
+// isAlertRaisedAction 新たにアラートが発生したアクションかどうか
+func isAlertRaisedAction(action string) bool {
+	switch action {
+	case "created", "reopened", "reintroduced":
+		return true
+	}
+	return false
+}
+
+// codeScanningSeverity コードスキャンのルールの重要度を共通の重要度に揃える
+func codeScanningSeverity(level string, severity string) string {
+	if level != "" {
+		return level
+	}
+	switch severity {
+	case "error":
+		return "high"
+	case "warning":
+		return "medium"
+	case "note":
+		return "low"
+	}
+	return ""
+}
+
+// parseDependabotAlertEvent dependabot_alertイベントをパースする
+func (summary *EventSummary) parseDependabotAlertEvent(payload []byte) error {
+	evt := dependabotAlertEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	action := stringValue(evt.Action)
+	if !isAlertRaisedAction(action) {
+		return ErrUnhandledAction
+	}
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	alert := evt.Alert
+	if alert == nil {
+		return missingField("alert")
+	}
+	if alert.SecurityAdvisory == nil {
+		return missingField("alert.security_advisory")
+	}
+	ecosystem, name, manifest := "", "", ""
+	if dependency := alert.Dependency; dependency != nil {
+		manifest = stringValue(dependency.ManifestPath)
+		if dependency.Package != nil {
+			ecosystem = stringValue(dependency.Package.Ecosystem)
+			name = stringValue(dependency.Package.Name)
+		}
+	}
+	summary.setRepository(evt.Repo)
+	summary.Action = action
+	summary.Kind = "dependabot_alert"
+	summary.Number = intValue(alert.Number)
+	summary.State = stringValue(alert.State)
+	summary.Title = stringValue(alert.SecurityAdvisory.Summary)
+	summary.URL = stringValue(alert.HTMLURL)
+	summary.Severity = stringValue(alert.SecurityAdvisory.Severity)
+	summary.Comment = fmt.Sprintf("*Package:* %v/%v\n*Manifest:* %v", ecosystem, name, manifest)
+	return nil
+}
+
+// parseCodeScanningAlertEvent code_scanning_alertイベントをパースする
+func (summary *EventSummary) parseCodeScanningAlertEvent(payload []byte) error {
+	evt := codeScanningAlertEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	action := stringValue(evt.Action)
+	if !isAlertRaisedAction(action) {
+		return ErrUnhandledAction
+	}
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	alert := evt.Alert
+	if alert == nil {
+		return missingField("alert")
+	}
+	rule := alert.Rule
+	if rule == nil {
+		return missingField("alert.rule")
+	}
+	tool := ""
+	if alert.Tool != nil {
+		tool = stringValue(alert.Tool.Name)
+	}
+	summary.setRepository(evt.Repo)
+	summary.Action = action
+	summary.Kind = "code_scanning_alert"
+	summary.Number = intValue(alert.Number)
+	summary.State = stringValue(alert.State)
+	summary.Title = stringValue(rule.Description)
+	summary.URL = stringValue(alert.HTMLURL)
+	summary.Severity = codeScanningSeverity(stringValue(rule.SecuritySeverityLevel), stringValue(rule.Severity))
+	summary.Comment = fmt.Sprintf("*Rule:* %v (%v)", stringValue(rule.ID), tool)
+	if instance := alert.MostRecentInstance; instance != nil && instance.Location != nil {
+		location := stringValue(instance.Location.Path)
+		if instance.Location.StartLine != nil {
+			location = fmt.Sprintf("%v:%v", location, *instance.Location.StartLine)
+		}
+		summary.Comment = fmt.Sprintf("%v\n*File:* %v", summary.Comment, location)
+	}
+	return nil
+}
+
+// parseSecretScanningAlertEvent secret_scanning_alertイベントをパースする
+func (summary *EventSummary) parseSecretScanningAlertEvent(payload []byte) error {
+	evt := secretScanningAlertEvent{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return err
+	}
+	action := stringValue(evt.Action)
+	if !isAlertRaisedAction(action) {
+		return ErrUnhandledAction
+	}
+	if evt.Repo == nil {
+		return missingField("repository")
+	}
+	alert := evt.Alert
+	if alert == nil {
+		return missingField("alert")
+	}
+	name := stringValue(alert.SecretType)
+	if alert.SecretTypeDisplayName != nil {
+		name = *alert.SecretTypeDisplayName
+	}
+	summary.setRepository(evt.Repo)
+	summary.Action = action
+	summary.Kind = "secret_scanning_alert"
+	summary.Number = intValue(alert.Number)
+	summary.State = stringValue(alert.State)
+	summary.Title = name
+	summary.URL = stringValue(alert.HTMLURL)
+	// シークレットの漏洩には重要度が付かないので常に high とする
+	summary.Severity = "high"
+	summary.Comment = fmt.Sprintf("*Secret:* %v", stringValue(alert.SecretType))
+	return nil
+}
@@ -0,0 +1,139 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestSeverityAtLeast(t *testing.T) {
+	type args struct {
+		severity  string
+		threshold string
+	}
+	table := map[args]bool{
+		args{"critical", "high"}:   true,
+		args{"high", "high"}:       true,
+		args{"moderate", "high"}:   false,
+		args{"medium", "low"}:      true,
+		args{"medium", "moderate"}: true,
+		args{"low", ""}:            true,
+		args{"", "critical"}:       true,
+		args{"unknown", "high"}:    true,
+	}
+	for args, expected := range table {
+		if severityAtLeast(args.severity, args.threshold) != expected {
+			t.Fatal("failed: "+args.severity+" >= "+args.threshold, expected)
+		}
+	}
+}
+
+func TestParseDependabotAlertEvent(t *testing.T) {
+	payload := `{
+		"action": "created",
+		"alert": {
+			"html_url": "url",
+			"dependency": {
+				"package": {"ecosystem": "npm", "name": "lodash"},
+				"manifest_path": "package-lock.json"
+			},
+			"security_advisory": {"summary": "Prototype Pollution", "severity": "high"}
+		},
+		"repository": {"name": "repo-name"}
+	}`
+	summary := EventSummary{}
+	err := summary.parseDependabotAlertEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse action: created", err)
+	}
+	if summary.RepositoryName != "repo-name" {
+		t.Fatal("failed: RepositoryName")
+	}
+	if summary.Title != "Prototype Pollution" {
+		t.Fatal("failed: Title")
+	}
+	if summary.URL != "url" {
+		t.Fatal("failed: URL")
+	}
+	if summary.Severity != "high" {
+		t.Fatal("failed: Severity")
+	}
+	if describe(summary) != "Dependabot alert created (high)" {
+		t.Fatal("failed: Description", describe(summary))
+	}
+	if summary.Comment != "*Package:* npm/lodash\n*Manifest:* package-lock.json" {
+		t.Fatal("failed: Comment", summary.Comment)
+	}
+
+	summary = EventSummary{}
+	err = summary.parseDependabotAlertEvent([]byte(`{"action": "dismissed"}`))
+	if err != ErrUnhandledAction {
+		t.Fatal("failed: unexpected error")
+	}
+}
+
+func TestParseCodeScanningAlertEvent(t *testing.T) {
+	payload := `{
+		"action": "created",
+		"alert": {
+			"html_url": "url",
+			"rule": {"id": "js/xss", "description": "Cross-site scripting", "severity": "error"},
+			"tool": {"name": "CodeQL"},
+			"most_recent_instance": {"location": {"path": "src/a.js", "start_line": 12}}
+		},
+		"repository": {"name": "repo-name"}
+	}`
+	summary := EventSummary{}
+	err := summary.parseCodeScanningAlertEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse action: created", err)
+	}
+	if summary.Title != "Cross-site scripting" {
+		t.Fatal("failed: Title")
+	}
+	if summary.Severity != "high" {
+		t.Fatal("failed: Severity", summary.Severity)
+	}
+	if describe(summary) != "Code scanning alert created (high)" {
+		t.Fatal("failed: Description", describe(summary))
+	}
+	if summary.Comment != "*Rule:* js/xss (CodeQL)\n*File:* src/a.js:12" {
+		t.Fatal("failed: Comment", summary.Comment)
+	}
+
+	summary = EventSummary{}
+	err = summary.parseCodeScanningAlertEvent([]byte(`{"action": "fixed"}`))
+	if err != ErrUnhandledAction {
+		t.Fatal("failed: unexpected error")
+	}
+}
+
+func TestParseSecretScanningAlertEvent(t *testing.T) {
+	payload := `{
+		"action": "created",
+		"alert": {
+			"html_url": "url",
+			"secret_type": "github_personal_access_token",
+			"secret_type_display_name": "GitHub Personal Access Token"
+		},
+		"repository": {"name": "repo-name"}
+	}`
+	summary := EventSummary{}
+	err := summary.parseSecretScanningAlertEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse action: created", err)
+	}
+	if summary.Title != "GitHub Personal Access Token" {
+		t.Fatal("failed: Title")
+	}
+	if summary.Severity != "high" {
+		t.Fatal("failed: Severity")
+	}
+	if summary.Comment != "*Secret:* github_personal_access_token" {
+		t.Fatal("failed: Comment", summary.Comment)
+	}
+
+	summary = EventSummary{}
+	err = summary.parseSecretScanningAlertEvent([]byte(`{"action": "resolved"}`))
+	if err != ErrUnhandledAction {
+		t.Fatal("failed: unexpected error")
+	}
+}
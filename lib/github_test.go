@@ -2,7 +2,9 @@ package lib
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-github/github"
 )
@@ -107,7 +109,7 @@ func TestParseIssuesEvent(t *testing.T) {
 	if summary.URL != htmlURL {
 		t.Fatal("failed: URL")
 	}
-	if summary.Description != "Issue "+opened+" by: "+user {
+	if describe(summary) != "Issue "+opened+" by: "+user {
 		t.Fatal("failed: Description")
 	}
 	if summary.Comment != body {
@@ -121,7 +123,7 @@ func TestParseIssuesEvent(t *testing.T) {
 	if err != nil {
 		t.Fatal("failed: parse action: edited")
 	}
-	if summary.Description != "Issue "+edited+" by: "+user {
+	if describe(summary) != "Issue "+edited+" by: "+user {
 		t.Fatal("failed: Description")
 	}
 
@@ -174,7 +176,7 @@ func TestParseIssueCommentsEvent(t *testing.T) {
 	if summary.URL != htmlURL {
 		t.Fatal("failed: URL")
 	}
-	if summary.Description != "Comment "+created+" by: "+user {
+	if describe(summary) != "Comment "+created+" by: "+user {
 		t.Fatal("failed: Description")
 	}
 	if summary.Comment != body {
@@ -188,7 +190,7 @@ func TestParseIssueCommentsEvent(t *testing.T) {
 	if err != nil {
 		t.Fatal("failed: parse action: edited")
 	}
-	if summary.Description != "Comment "+edited+" by: "+user {
+	if describe(summary) != "Comment "+edited+" by: "+user {
 		t.Fatal("failed: Description")
 	}
 
@@ -239,7 +241,7 @@ func TestParsePullRequestEvent(t *testing.T) {
 	if summary.URL != htmlURL {
 		t.Fatal("failed: URL")
 	}
-	if summary.Description != "PullRequest "+opened+" by: "+user {
+	if describe(summary) != "PullRequest "+opened+" by: "+user {
 		t.Fatal("failed: Description")
 	}
 	if summary.Comment != body {
@@ -253,7 +255,7 @@ func TestParsePullRequestEvent(t *testing.T) {
 	if err != nil {
 		t.Fatal("failed: parse action: edited")
 	}
-	if summary.Description != "PullRequest "+edited+" by: "+user {
+	if describe(summary) != "PullRequest "+edited+" by: "+user {
 		t.Fatal("failed: Description")
 	}
 
@@ -306,7 +308,7 @@ func TestParsePullRequestReviewEvent(t *testing.T) {
 	if summary.URL != htmlURL {
 		t.Fatal("failed: URL")
 	}
-	if summary.Description != "Review "+submitted+" by: "+user {
+	if describe(summary) != "Review "+submitted+" by: "+user {
 		t.Fatal("failed: Description")
 	}
 	if summary.Comment != body {
@@ -320,7 +322,7 @@ func TestParsePullRequestReviewEvent(t *testing.T) {
 	if err != nil {
 		t.Fatal("failed: parse action: edited")
 	}
-	if summary.Description != "Review "+edited+" by: "+user {
+	if describe(summary) != "Review "+edited+" by: "+user {
 		t.Fatal("failed: Description")
 	}
 
@@ -373,7 +375,7 @@ func TestParsePullRequestReviewCommentEvent(t *testing.T) {
 	if summary.URL != htmlURL {
 		t.Fatal("failed: URL")
 	}
-	if summary.Description != "Comment "+created+" by: "+user {
+	if describe(summary) != "Comment "+created+" by: "+user {
 		t.Fatal("failed: Description")
 	}
 	if summary.Comment != body {
@@ -387,7 +389,7 @@ func TestParsePullRequestReviewCommentEvent(t *testing.T) {
 	if err != nil {
 		t.Fatal("failed: parse action: edited")
 	}
-	if summary.Description != "Comment "+edited+" by: "+user {
+	if describe(summary) != "Comment "+edited+" by: "+user {
 		t.Fatal("failed: Description")
 	}
 
@@ -399,3 +401,412 @@ func TestParsePullRequestReviewCommentEvent(t *testing.T) {
 		t.Fatal("failed: unexpected error")
 	}
 }
+
+func TestFindRecipientAccounts(t *testing.T) {
+	config := Config{
+		Accounts: map[string]Account{
+			"@a": Account{
+				ID:      "@aa",
+				Channel: "aaa",
+			},
+		},
+	}
+	result := FindRecipientAccounts([]string{"@a", "@c"}, config)
+	if account, ok := result["@a"]; !ok || account.ID != "@aa" {
+		t.Fatal("cannot get account")
+	}
+	if _, ok := result["@c"]; ok {
+		t.Fatal("get invalid account")
+	}
+}
+
+func TestFindSubscribers(t *testing.T) {
+	config := Config{
+		Subscriptions: map[string][]Subscription{
+			"repo": []Subscription{
+				{
+					Channel: "aaa",
+					Events:  []string{"release"},
+				},
+				{
+					Channel: "bbb",
+					Events:  []string{"issues"},
+				},
+			},
+		},
+	}
+	result1 := FindSubscribers(EventSummary{Event: "release", RepositoryName: "repo"}, config)
+	if len(result1) != 1 {
+		t.Fatal("get invalid subscribers", result1)
+	}
+	if account, ok := result1["aaa"]; !ok || account.Channel != "aaa" {
+		t.Fatal("cannot get subscriber")
+	}
+	result2 := FindSubscribers(EventSummary{Event: "release", RepositoryName: "other"}, config)
+	if len(result2) != 0 {
+		t.Fatal("get invalid subscribers", result2)
+	}
+}
+
+func TestFindSubscribersFullName(t *testing.T) {
+	config := Config{
+		Subscriptions: map[string][]Subscription{
+			"orgA/api": []Subscription{
+				{Channel: "aaa", Events: []string{"release"}},
+			},
+			"api": []Subscription{
+				{Channel: "bbb", Events: []string{"release"}},
+			},
+		},
+		Repositories: map[string]Repository{
+			"orgA/api": Repository{Owners: []string{"@owner"}},
+		},
+		Accounts: map[string]Account{
+			"@owner": Account{ID: "@owner"},
+		},
+	}
+	result1 := FindSubscribers(EventSummary{Event: "release", RepositoryName: "api", RepositoryFullName: "orgA/api"}, config)
+	if len(result1) != 2 {
+		t.Fatal("get invalid subscribers", result1)
+	}
+	result2 := FindSubscribers(EventSummary{Event: "release", RepositoryName: "api", RepositoryFullName: "orgB/api"}, config)
+	if _, ok := result2["aaa"]; ok || len(result2) != 1 {
+		t.Fatal("get invalid subscribers", result2)
+	}
+	result3 := FindOwners(EventSummary{Event: "dependabot_alert", RepositoryName: "api", RepositoryFullName: "orgA/api"}, config)
+	if _, ok := result3["@owner"]; !ok {
+		t.Fatal("cannot get owner")
+	}
+	result4 := FindOwners(EventSummary{Event: "dependabot_alert", RepositoryName: "api", RepositoryFullName: "orgB/api"}, config)
+	if len(result4) != 0 {
+		t.Fatal("get invalid owners", result4)
+	}
+}
+
+func TestFindSubscribersMinSeverity(t *testing.T) {
+	config := Config{
+		Subscriptions: map[string][]Subscription{
+			"repo": []Subscription{
+				{
+					Channel:     "aaa",
+					Events:      []string{"dependabot_alert"},
+					MinSeverity: "high",
+				},
+			},
+		},
+	}
+	result1 := FindSubscribers(EventSummary{Event: "dependabot_alert", RepositoryName: "repo", Severity: "critical"}, config)
+	if _, ok := result1["aaa"]; !ok {
+		t.Fatal("cannot get subscriber")
+	}
+	result2 := FindSubscribers(EventSummary{Event: "dependabot_alert", RepositoryName: "repo", Severity: "low"}, config)
+	if len(result2) != 0 {
+		t.Fatal("get invalid subscribers", result2)
+	}
+}
+
+func TestFindOwners(t *testing.T) {
+	config := Config{
+		Accounts: map[string]Account{
+			"@a": Account{
+				ID:      "@aa",
+				Channel: "aaa",
+			},
+			"@b": Account{
+				ID:          "@bb",
+				Channel:     "bbb",
+				MinSeverity: "critical",
+			},
+		},
+		Repositories: map[string]Repository{
+			"repo": Repository{
+				Owners: []string{"@a", "@b"},
+			},
+		},
+	}
+	result1 := FindOwners(EventSummary{Event: "dependabot_alert", RepositoryName: "repo", Severity: "high"}, config)
+	if len(result1) != 1 {
+		t.Fatal("get invalid owners", result1)
+	}
+	if _, ok := result1["@a"]; !ok {
+		t.Fatal("cannot get owner")
+	}
+	result2 := FindOwners(EventSummary{Event: "dependabot_alert", RepositoryName: "repo", Severity: "critical"}, config)
+	if len(result2) != 2 {
+		t.Fatal("get invalid owners", result2)
+	}
+	result3 := FindOwners(EventSummary{Event: "issues", RepositoryName: "repo"}, config)
+	if len(result3) != 0 {
+		t.Fatal("get invalid owners", result3)
+	}
+}
+
+func TestParseLabeledEvent(t *testing.T) {
+	payload := `{
+		"action": "labeled",
+		"label": {"name": "incident"},
+		"issue": {"title": "issue-title", "html_url": "url", "body": "@a body", "user": {"login": "user"}},
+		"repository": {"name": "repo-name"},
+		"sender": {"login": "sender"}
+	}`
+	summary := EventSummary{}
+	err := summary.parseIssuesEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse action: labeled", err)
+	}
+	if describe(summary) != "Issue labeled incident by: sender" {
+		t.Fatal("failed: Description", describe(summary))
+	}
+	if summary.Comment != "" {
+		t.Fatal("failed: Comment", summary.Comment)
+	}
+	if len(summary.Labels) != 1 || summary.Labels[0] != "incident" {
+		t.Fatal("failed: Labels", summary.Labels)
+	}
+
+	payload = `{
+		"action": "opened",
+		"pull_request": {"title": "pr-title", "html_url": "url", "body": "body", "user": {"login": "user"}, "labels": [{"name": "bug"}, {"name": "incident"}]},
+		"repository": {"name": "repo-name"},
+		"sender": {"login": "sender"}
+	}`
+	summary = EventSummary{}
+	err = summary.parsePullRequestEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse action: opened", err)
+	}
+	if len(summary.Labels) != 2 || summary.Labels[1] != "incident" {
+		t.Fatal("failed: Labels", summary.Labels)
+	}
+}
+
+func TestFindLabelSubscribers(t *testing.T) {
+	config := Config{
+		Accounts: map[string]Account{
+			"@a": Account{
+				ID:      "@aa",
+				Channel: "aaa",
+				Labels: []LabelSubscription{
+					{Repository: "repo", Label: "incident"},
+				},
+			},
+			"@b": Account{
+				ID:      "@bb",
+				Channel: "bbb",
+				Labels: []LabelSubscription{
+					{Label: "bug*"},
+				},
+			},
+			"@c": Account{
+				ID:      "@cc",
+				Channel: "ccc",
+				Labels: []LabelSubscription{
+					{Repository: "orgA/*", Label: "incident"},
+				},
+			},
+		},
+	}
+	result1 := FindLabelSubscribers(EventSummary{Action: "labeled", RepositoryName: "repo", Labels: []string{"incident"}}, config)
+	if len(result1) != 1 {
+		t.Fatal("get invalid subscribers", result1)
+	}
+	if _, ok := result1["@a"]; !ok {
+		t.Fatal("cannot get subscriber")
+	}
+	result2 := FindLabelSubscribers(EventSummary{Action: "opened", RepositoryName: "other", Labels: []string{"incident", "bugfix"}}, config)
+	if len(result2) != 1 {
+		t.Fatal("get invalid subscribers", result2)
+	}
+	if _, ok := result2["@b"]; !ok {
+		t.Fatal("cannot get subscriber")
+	}
+	result3 := FindLabelSubscribers(EventSummary{Action: "edited", RepositoryName: "repo", Labels: []string{"incident"}}, config)
+	if len(result3) != 0 {
+		t.Fatal("get invalid subscribers", result3)
+	}
+	result4 := FindLabelSubscribers(EventSummary{Action: "labeled", RepositoryName: "repo", RepositoryFullName: "orgA/repo", Labels: []string{"incident"}}, config)
+	if _, ok := result4["@c"]; !ok || len(result4) != 2 {
+		t.Fatal("get invalid subscribers", result4)
+	}
+}
+
+func TestFindAdminChannel(t *testing.T) {
+	config := Config{
+		AdminChannel: "aaa",
+	}
+	result1 := FindAdminChannel(EventSummary{Event: "member"}, config)
+	if account, ok := result1["aaa"]; !ok || account.Channel != "aaa" {
+		t.Fatal("cannot get admin channel")
+	}
+	result2 := FindAdminChannel(EventSummary{Event: "issues"}, config)
+	if len(result2) != 0 {
+		t.Fatal("get invalid admin channel", result2)
+	}
+}
+
+func TestFindSubscribersRefs(t *testing.T) {
+	config := Config{
+		Subscriptions: map[string][]Subscription{
+			"repo": []Subscription{
+				{
+					Channel: "aaa",
+					Events:  []string{"create"},
+					Refs:    []string{"branch:release/*", "tag:v*"},
+				},
+			},
+		},
+	}
+	result1 := FindSubscribers(EventSummary{Event: "create", RepositoryName: "repo", Ref: "v1.0", RefType: "tag"}, config)
+	if _, ok := result1["aaa"]; !ok {
+		t.Fatal("cannot get subscriber")
+	}
+	result2 := FindSubscribers(EventSummary{Event: "create", RepositoryName: "repo", Ref: "feature/a", RefType: "branch"}, config)
+	if len(result2) != 0 {
+		t.Fatal("get invalid subscribers", result2)
+	}
+}
+
+func TestParseStructuredSummary(t *testing.T) {
+	payload := `{
+		"action": "opened",
+		"repository": {"name": "repo", "full_name": "org/repo", "html_url": "https://github.com/org/repo"},
+		"issue": {
+			"number": 12,
+			"state": "open",
+			"title": "title",
+			"body": "body",
+			"created_at": "2018-06-01T10:00:00Z",
+			"user": {"login": "user", "avatar_url": "https://avatars.example.com/user"},
+			"labels": [{"name": "bug"}]
+		}
+	}`
+	summary := EventSummary{}
+	err := summary.parseIssuesEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse", err)
+	}
+	if summary.Kind != "issue" || summary.Action != "opened" {
+		t.Fatal("failed: Kind/Action", summary.Kind, summary.Action)
+	}
+	if summary.Actor.Login != "user" || summary.Actor.AvatarURL != "https://avatars.example.com/user" {
+		t.Fatal("failed: Actor", summary.Actor)
+	}
+	if summary.RepositoryFullName != "org/repo" || summary.RepositoryURL != "https://github.com/org/repo" {
+		t.Fatal("failed: Repository", summary.RepositoryFullName, summary.RepositoryURL)
+	}
+	if summary.Number != 12 || summary.State != "open" {
+		t.Fatal("failed: Number/State", summary.Number, summary.State)
+	}
+	if len(summary.Labels) != 1 || summary.Labels[0] != "bug" {
+		t.Fatal("failed: Labels", summary.Labels)
+	}
+	if !summary.CreatedAt.Equal(time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatal("failed: CreatedAt", summary.CreatedAt)
+	}
+}
+
+func TestParsePullRequestReviewCommentContext(t *testing.T) {
+	payload := `{
+		"action": "created",
+		"repository": {"name": "repo", "full_name": "org/repo", "html_url": "https://github.com/org/repo"},
+		"pull_request": {"number": 3, "title": "title"},
+		"comment": {
+			"html_url": "url",
+			"body": "this is wrong",
+			"path": "lib/a.go",
+			"commit_id": "0123456789abcdef",
+			"start_line": 11,
+			"line": 12,
+			"diff_hunk": "@@ -1,6 +1,7 @@\n a\n b\n c\n-d\n+e\n+f\n g",
+			"user": {"login": "user"}
+		}
+	}`
+	summary := EventSummary{}
+	err := summary.parsePullRequestReviewCommentEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse", err)
+	}
+	if summary.Path != "lib/a.go" || summary.StartLine != 11 || summary.Line != 12 || summary.CommitID != "0123456789abcdef" {
+		t.Fatal("failed: location", summary.Path, summary.StartLine, summary.Line, summary.CommitID)
+	}
+	expected := "Comment created by: user on <https://github.com/org/repo/blob/0123456789abcdef/lib/a.go#L11-L12|lib/a.go:11-12>"
+	if describe(summary) != expected {
+		t.Fatal("failed: Description", describe(summary))
+	}
+	renderer, _ := NewRenderer(Config{Templates: map[string]string{"default": `{{.CodeContext}}|{{.Comment}}`}})
+	result, _ := renderer.Render(summary)
+	if result != "```\n-d\n+e\n+f\n g\n```|this is wrong" {
+		t.Fatal("failed: code context", result)
+	}
+
+	// 古くなったコメントは元の行番号を使う
+	payload = strings.Replace(payload, `"start_line": 11,`, `"original_line": 8,`, 1)
+	payload = strings.Replace(payload, `"line": 12,`, ``, 1)
+	summary = EventSummary{}
+	summary.parsePullRequestReviewCommentEvent([]byte(payload))
+	if summary.Line != 8 || summary.StartLine != 0 {
+		t.Fatal("failed: original line", summary.Line, summary.StartLine)
+	}
+}
+
+func TestParsePullRequestReviewCommentThread(t *testing.T) {
+	payload := `{
+		"action": "created",
+		"repository": {"name": "repo", "full_name": "org/repo"},
+		"pull_request": {"number": 3},
+		"comment": {"id": 20, "body": "reply", "user": {"login": "user"}}
+	}`
+	summary := EventSummary{}
+	summary.parsePullRequestReviewCommentEvent([]byte(payload))
+	if summary.ReviewThreadID != 20 {
+		t.Fatal("failed: thread id", summary.ReviewThreadID)
+	}
+
+	// スレッドへの返信は先頭のコメントへ返信する
+	payload = strings.Replace(payload, `"id": 20,`, `"id": 20, "in_reply_to_id": 10,`, 1)
+	summary = EventSummary{}
+	summary.parsePullRequestReviewCommentEvent([]byte(payload))
+	if summary.ReviewThreadID != 10 {
+		t.Fatal("failed: in reply to", summary.ReviewThreadID)
+	}
+}
+
+func TestParsePullRequestReviewRequested(t *testing.T) {
+	payload := `{
+		"action": "review_requested",
+		"repository": {"name": "repo", "full_name": "org/repo"},
+		"pull_request": {"number": 3, "title": "title", "head": {"ref": "hotfix/login"}},
+		"requested_reviewer": {"login": "reviewer"},
+		"sender": {"login": "author"}
+	}`
+	summary := EventSummary{}
+	err := summary.parsePullRequestEvent([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse", err)
+	}
+	if summary.Action != "review_requested" || summary.Actor.Login != "author" || summary.HeadRef != "hotfix/login" {
+		t.Fatal("failed: review requested", summary)
+	}
+	if len(summary.Recipients) != 1 || summary.Recipients[0] != "@reviewer" {
+		t.Fatal("failed: recipients", summary.Recipients)
+	}
+}
+
+func TestThreadKey(t *testing.T) {
+	type fromTo struct {
+		from EventSummary
+		to   string
+	}
+	table := []fromTo{
+		{EventSummary{Event: "issues", RepositoryFullName: "org/repo", Number: 12}, "org/repo#12"},
+		{EventSummary{Event: "pull_request_review_comment", RepositoryFullName: "org/repo", Number: 12}, "org/repo#12"},
+		{EventSummary{Event: "workflow_run", RepositoryFullName: "org/repo", Number: 12}, ""},
+		{EventSummary{Event: "code_scanning_alert", RepositoryFullName: "org/repo", Number: 12}, ""},
+		{EventSummary{Event: "issues", RepositoryFullName: "org/repo"}, ""},
+	}
+	for _, fromTo := range table {
+		if key := fromTo.from.ThreadKey(); key != fromTo.to {
+			t.Fatal("failed: "+fromTo.from.Event, "expect: "+fromTo.to, "actual: "+key)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package lib
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	mdFenceReg       = regexp.MustCompile("^[ \t]*(```+|~~~+)")
+	mdHTMLCommentReg = regexp.MustCompile(`(?s)<!--.*?-->`)
+	mdSummaryReg     = regexp.MustCompile(`(?is)<summary>[ \t\n]*(.*?)[ \t\n]*</summary>`)
+	mdBreakReg       = regexp.MustCompile(`(?i)<br[ \t]*/?>`)
+	mdHTMLTagReg     = regexp.MustCompile(`(?i)</?(details|p|div|sub|sup|kbd|b|i|em|strong)(\s[^>]*)?>`)
+	mdQuoteReg       = regexp.MustCompile(`^([ \t]*>[ \t]?)+`)
+	mdHeadingReg     = regexp.MustCompile(`^#{1,6}[ \t]+(.+?)[ \t#]*$`)
+	mdTaskReg        = regexp.MustCompile(`^([ \t]*)[-*+][ \t]+\[([ xX])\][ \t]+`)
+	mdListReg        = regexp.MustCompile(`^([ \t]*)[-*+][ \t]+`)
+	mdTableRuleReg   = regexp.MustCompile(`^[ \t]*\|?([ \t]*:?-+:?[ \t]*\|)+([ \t]*:?-+:?[ \t]*)?$`)
+	mdTableRowReg    = regexp.MustCompile(`^[ \t]*\|(.*)\|[ \t]*$`)
+	mdCodeSpanReg    = regexp.MustCompile("`[^`\n]+`")
+	mdImageReg       = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)[^)]*\)`)
+	mdLinkReg        = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)[^)]*\)`)
+	mdAutoLinkReg    = regexp.MustCompile(`<(https?://[^>\s]+)>`)
+	mdBoldReg        = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	mdStrikeReg      = regexp.MustCompile(`~~(.+?)~~`)
+	mdItalicReg      = regexp.MustCompile(`\*([^*\s][^*]*?)\*|\b_([^_\s][^_]*?)_\b`)
+	mdProtectedReg   = regexp.MustCompile("\x01([0-9]+)\x01")
+)
+
+// markdownKinds 本文がGithubのMarkdownで書かれている種類
+// それ以外の種類はパーサがmrkdwnで本文を組み立てている
+var markdownKinds = map[string]bool{
+	"issue":          true,
+	"pull_request":   true,
+	"comment":        true,
+	"review":         true,
+	"commit_comment": true,
+	"discussion":     true,
+	"release":        true,
+}
+
+// toSlackMarkdown GithubのMarkdownをSlackのmrkdwnに変換する
+// コードブロックの中身はそのまま残す
+// 変換で生成したリンク以外の & < > はエスケープするので、本文の <!channel> などは効かない
+func toSlackMarkdown(text string) string {
+	lines := strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+	converted := []string{}
+	block := []string{}
+	fence := ""
+	suggestion := false
+	for _, line := range lines {
+		if fence == "" {
+			if m := mdFenceReg.FindStringSubmatch(line); m != nil {
+				converted = append(converted, convertMarkdownBlock(strings.Join(block, "\n"))...)
+				block = []string{}
+				// Slackは言語指定を解釈しないので開始行は記号だけにする
+				fence = m[1]
+				suggestion = strings.TrimSpace(line[len(m[0]):]) == "suggestion"
+				if suggestion {
+					converted = append(converted, "*Suggested change:*")
+				}
+				converted = append(converted, "```")
+				continue
+			}
+			block = append(block, line)
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), fence) {
+			fence = ""
+			converted = append(converted, "```")
+			continue
+		}
+		if suggestion {
+			// 提案された内容は置き換え後の行として表示する
+			line = "+" + line
+		}
+		converted = append(converted, mrkdwnEscaper.Replace(line))
+	}
+	converted = append(converted, convertMarkdownBlock(strings.Join(block, "\n"))...)
+	// 閉じられていないコードブロックは閉じておく
+	if fence != "" {
+		converted = append(converted, "```")
+	}
+	return strings.Join(converted, "\n")
+}
+
+// convertMarkdownBlock コードブロック以外の部分を行毎に変換する
+func convertMarkdownBlock(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = mdHTMLCommentReg.ReplaceAllString(text, "")
+	text = mdSummaryReg.ReplaceAllString(text, "**$1**")
+	text = mdBreakReg.ReplaceAllString(text, "\n")
+	text = mdHTMLTagReg.ReplaceAllString(text, "")
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		// 引用記号はエスケープせずに残す
+		quote := mdQuoteReg.FindString(line)
+		line = line[len(quote):]
+		if mdTableRuleReg.MatchString(line) {
+			continue
+		}
+		if m := mdTableRowReg.FindStringSubmatch(line); m != nil {
+			cells := strings.Split(m[1], "|")
+			for i := range cells {
+				cells[i] = strings.TrimSpace(cells[i])
+			}
+			line = strings.Join(cells, " | ")
+		}
+		if m := mdHeadingReg.FindStringSubmatch(line); m != nil {
+			lines = append(lines, quote+"*"+strings.Trim(convertInline(m[1]), "*")+"*")
+			continue
+		}
+		if m := mdTaskReg.FindStringSubmatch(line); m != nil {
+			box := "☐ "
+			if m[2] != " " {
+				box = "☑ "
+			}
+			line = m[1] + box + line[len(m[0]):]
+		} else {
+			line = mdListReg.ReplaceAllString(line, "$1• ")
+		}
+		lines = append(lines, quote+convertInline(line))
+	}
+	return compactBlankLines(lines)
+}
+
+// compactBlankLines タグやコメントを取り除いて残った空行を詰める
+func compactBlankLines(lines []string) []string {
+	compacted := []string{}
+	for _, line := range lines {
+		blank := strings.TrimSpace(line) == ""
+		if blank && (len(compacted) == 0 || compacted[len(compacted)-1] == "") {
+			continue
+		}
+		if blank {
+			line = ""
+		}
+		compacted = append(compacted, line)
+	}
+	if len(compacted) > 0 && compacted[len(compacted)-1] == "" {
+		compacted = compacted[:len(compacted)-1]
+	}
+	return compacted
+}
+
+// convertInline 行内の強調やリンクを変換する
+// インラインコードと変換後のリンクは強調の変換対象から外す
+func convertInline(line string) string {
+	protected := []string{}
+	protect := func(text string) string {
+		protected = append(protected, text)
+		return "\x01" + strconv.Itoa(len(protected)-1) + "\x01"
+	}
+	line = mdCodeSpanReg.ReplaceAllStringFunc(line, func(text string) string {
+		return protect(mrkdwnEscaper.Replace(text))
+	})
+	line = mdImageReg.ReplaceAllStringFunc(line, func(text string) string {
+		m := mdImageReg.FindStringSubmatch(text)
+		return protect(slackLink(m[2], m[1]))
+	})
+	line = mdLinkReg.ReplaceAllStringFunc(line, func(text string) string {
+		m := mdLinkReg.FindStringSubmatch(text)
+		return protect(slackLink(m[2], m[1]))
+	})
+	line = mdAutoLinkReg.ReplaceAllStringFunc(line, func(text string) string {
+		return protect(slackLink(mdAutoLinkReg.FindStringSubmatch(text)[1], ""))
+	})
+	line = mrkdwnEscaper.Replace(line)
+	// 太字はSlackでは * なので、斜体の変換に巻き込まれないよう一旦別の記号にする
+	line = mdBoldReg.ReplaceAllString(line, "\x02$1$2\x02")
+	line = mdStrikeReg.ReplaceAllString(line, "~$1~")
+	line = mdItalicReg.ReplaceAllString(line, "_${1}${2}_")
+	line = strings.Replace(line, "\x02", "*", -1)
+	return mdProtectedReg.ReplaceAllStringFunc(line, func(text string) string {
+		i, _ := strconv.Atoi(strings.Trim(text, "\x01"))
+		return protected[i]
+	})
+}
+
+// slackLink URLと表示文字列をエスケープしてリンク記法にする
+func slackLink(url string, text string) string {
+	if text == "" {
+		return "<" + mrkdwnEscaper.Replace(url) + ">"
+	}
+	return "<" + mrkdwnEscaper.Replace(url) + "|" + mrkdwnEscaper.Replace(text) + ">"
+}
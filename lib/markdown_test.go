@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+func TestToSlackMarkdown(t *testing.T) {
+	table := map[string]string{
+		"## Changes":                      "*Changes*",
+		"**bold** and __bold__":           "*bold* and *bold*",
+		"see [docs](https://example.com)": "see <https://example.com|docs>",
+		"- a\n  * b":                      "• a\n  • b",
+		"plain":                           "plain",
+	}
+	for from, to := range table {
+		result := toSlackMarkdown(from)
+		if result != to {
+			t.Fatal("get invalid text", "\nfrom: "+from, "\nexpected: "+to, "\nactual: "+result)
+		}
+	}
+}
+
+// TestToSlackMarkdownGolden testdata/markdown の *.md を変換して *.golden と比較する
+// go test -run Golden -update で期待値を更新する
+func TestToSlackMarkdownGolden(t *testing.T) {
+	files, err := filepath.Glob("testdata/markdown/*.md")
+	if err != nil || len(files) == 0 {
+		t.Fatal("failed: find testdata", err)
+	}
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal("failed: read "+file, err)
+		}
+		result := toSlackMarkdown(strings.TrimSuffix(string(src), "\n"))
+		golden := strings.TrimSuffix(file, ".md") + ".golden"
+		if *updateGolden {
+			ioutil.WriteFile(golden, []byte(result+"\n"), 0644)
+		}
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal("failed: read "+golden, err)
+		}
+		if result+"\n" != string(expected) {
+			t.Fatal("failed: "+file, "\nexpected:\n"+string(expected), "\nactual:\n"+result)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidQuietHours 静かな時間帯の指定が "22:00-08:00" の形式になっていない
+var ErrInvalidQuietHours = errors.New("invalid_quiet_hours")
+
+// quietHours 通知を溜めておく時間帯 (0時からの分)
+// 開始と終了が同じ場合は時間帯なしとする
+type quietHours struct {
+	start int
+	end   int
+}
+
+// parseQuietHours "22:00-08:00" 形式の時間帯をパースする
+// 日付をまたぐ指定もできる
+func parseQuietHours(text string) (quietHours, error) {
+	if text == "" {
+		return quietHours{}, nil
+	}
+	parts := strings.Split(text, "-")
+	if len(parts) != 2 {
+		return quietHours{}, ErrInvalidQuietHours
+	}
+	minutes := []int{}
+	for _, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return quietHours{}, ErrInvalidQuietHours
+		}
+		minutes = append(minutes, t.Hour()*60+t.Minute())
+	}
+	return quietHours{start: minutes[0], end: minutes[1]}, nil
+}
+
+// contains 時刻 (0時からの分) が時間帯に含まれるか
+func (q quietHours) contains(minute int) bool {
+	if q.start == q.end {
+		return false
+	}
+	if q.start < q.end {
+		return q.start <= minute && minute < q.end
+	}
+	return minute >= q.start || minute < q.end
+}
+
+// location 静かな時間帯を解釈するタイムゾーン
+func (a Account) location() (*time.Location, error) {
+	if a.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(a.Timezone)
+}
+
+// QuietUntil 静かな時間帯であれば、その終わる時刻を返す
+// 設定が不正な場合は時間帯なしとして扱う (起動時に Config.Validate で検出する)
+func (a Account) QuietUntil(now time.Time) (time.Time, bool) {
+	q, err := parseQuietHours(a.QuietHours)
+	if err != nil {
+		return time.Time{}, false
+	}
+	loc, err := a.location()
+	if err != nil {
+		return time.Time{}, false
+	}
+	local := now.In(loc)
+	if !q.contains(local.Hour()*60 + local.Minute()) {
+		return time.Time{}, false
+	}
+	until := time.Date(local.Year(), local.Month(), local.Day(), q.end/60, q.end%60, 0, 0, loc)
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until, true
+}
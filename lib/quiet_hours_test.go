@@ -0,0 +1,124 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseQuietHours(t *testing.T) {
+	type fromTo struct {
+		from string
+		to   quietHours
+		err  error
+	}
+	list := []fromTo{
+		fromTo{from: "", to: quietHours{}, err: nil},
+		fromTo{from: "22:00-08:00", to: quietHours{start: 22 * 60, end: 8 * 60}, err: nil},
+		fromTo{from: "12:30 - 13:15", to: quietHours{start: 12*60 + 30, end: 13*60 + 15}, err: nil},
+		fromTo{from: "22:00", err: ErrInvalidQuietHours},
+		fromTo{from: "22-08", err: ErrInvalidQuietHours},
+		fromTo{from: "25:00-08:00", err: ErrInvalidQuietHours},
+	}
+	for _, ft := range list {
+		q, err := parseQuietHours(ft.from)
+		if err != ft.err || (err == nil && q != ft.to) {
+			t.Fatal("failed: parse", ft, q, err)
+		}
+	}
+}
+
+func TestQuietUntil(t *testing.T) {
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	account := Account{Timezone: "Asia/Tokyo", QuietHours: "22:00-08:00"}
+	type fromTo struct {
+		from  time.Time
+		quiet bool
+		to    time.Time
+	}
+	list := []fromTo{
+		fromTo{from: time.Date(2018, 1, 1, 21, 59, 0, 0, tokyo), quiet: false},
+		fromTo{from: time.Date(2018, 1, 1, 22, 0, 0, 0, tokyo), quiet: true, to: time.Date(2018, 1, 2, 8, 0, 0, 0, tokyo)},
+		fromTo{from: time.Date(2018, 1, 2, 7, 59, 30, 0, tokyo), quiet: true, to: time.Date(2018, 1, 2, 8, 0, 0, 0, tokyo)},
+		fromTo{from: time.Date(2018, 1, 2, 8, 0, 0, 0, tokyo), quiet: false},
+		// 他のタイムゾーンの時刻でもアカウントのタイムゾーンで判定する
+		fromTo{from: time.Date(2018, 1, 1, 14, 0, 0, 0, time.UTC), quiet: true, to: time.Date(2018, 1, 2, 8, 0, 0, 0, tokyo)},
+	}
+	for _, ft := range list {
+		until, quiet := account.QuietUntil(ft.from)
+		if quiet != ft.quiet || (quiet && !until.Equal(ft.to)) {
+			t.Fatal("failed: quiet until", ft, until, quiet)
+		}
+	}
+
+	daytime := Account{Timezone: "America/New_York", QuietHours: "12:00-13:00"}
+	if _, quiet := daytime.QuietUntil(time.Date(2018, 1, 1, 17, 30, 0, 0, time.UTC)); !quiet {
+		t.Fatal("failed: daytime quiet hours")
+	}
+	if _, quiet := (Account{}).QuietUntil(time.Now()); quiet {
+		t.Fatal("failed: no quiet hours")
+	}
+}
+
+func TestUrgentRule(t *testing.T) {
+	conf := Config{Urgent: []UrgentRule{
+		UrgentRule{Events: []string{"pull_request.review_requested"}, Refs: []string{"hotfix/*"}},
+		UrgentRule{Events: []string{"dependabot_alert"}},
+	}}
+	type fromTo struct {
+		from EventSummary
+		to   bool
+	}
+	list := []fromTo{
+		fromTo{from: EventSummary{Event: "pull_request", Action: "review_requested", HeadRef: "hotfix/login"}, to: true},
+		fromTo{from: EventSummary{Event: "pull_request", Action: "review_requested", HeadRef: "feature/login"}, to: false},
+		fromTo{from: EventSummary{Event: "pull_request", Action: "opened", HeadRef: "hotfix/login"}, to: false},
+		fromTo{from: EventSummary{Event: "dependabot_alert", Action: "created"}, to: true},
+		fromTo{from: EventSummary{Event: "push"}, to: false},
+	}
+	for _, ft := range list {
+		if conf.IsUrgent(ft.from) != ft.to {
+			t.Fatal("failed: urgent", ft)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	conf := Config{Accounts: map[string]Account{"@a": Account{Timezone: "Asia/Tokyo", QuietHours: "22:00-08:00"}}}
+	if err := conf.Validate(); err != nil {
+		t.Fatal("failed: valid config", err)
+	}
+	conf.Accounts["@b"] = Account{Timezone: "Mars/Olympus"}
+	if err := conf.Validate(); err == nil {
+		t.Fatal("failed: invalid timezone")
+	}
+	conf.Accounts["@b"] = Account{QuietHours: "night"}
+	if err := conf.Validate(); err == nil {
+		t.Fatal("failed: invalid quiet hours")
+	}
+	delete(conf.Accounts, "@b")
+	conf.BodyLimit.Chars = 10
+	if err := conf.Validate(); err == nil {
+		t.Fatal("failed: too small body limit")
+	}
+	conf.BodyLimit.Chars = minBodyLimitChars
+	if err := conf.Validate(); err != nil {
+		t.Fatal("failed: minimum body limit", err)
+	}
+	conf.Accounts["@a"] = Account{MinSeverity: "High"}
+	conf.Subscriptions = map[string][]Subscription{"repo": []Subscription{{MinSeverity: "critical"}}}
+	if err := conf.Validate(); err != nil {
+		t.Fatal("failed: valid min severity", err)
+	}
+	conf.Accounts["@a"] = Account{MinSeverity: "severe"}
+	if err := conf.Validate(); err == nil {
+		t.Fatal("failed: invalid account min severity")
+	}
+	conf.Accounts["@a"] = Account{MinSeverity: "moderate"}
+	if err := conf.Validate(); err != nil {
+		t.Fatal("failed: moderate min severity", err)
+	}
+	conf.Subscriptions["repo"][0].MinSeverity = "urgent"
+	if err := conf.Validate(); err == nil {
+		t.Fatal("failed: invalid subscription min severity")
+	}
+}
@@ -0,0 +1,132 @@
+package lib
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// EventParser Githubイベントのパーサ
+// RegisterEventParser で登録するとイベント名で引かれるようになる
+type EventParser interface {
+	// Event 対象のイベント名
+	Event() string
+	// Actions 対象のアクション一覧 (アクションを持たないイベントは空)
+	Actions() []string
+	// Parse ペイロードからサマリ一覧を生成する
+	Parse(payload []byte) ([]EventSummary, error)
+}
+
+// eventParsers イベント名をキーとしたパーサ一覧
+var eventParsers = map[string]EventParser{}
+
+// RegisterEventParser パーサを登録する
+// 同じイベント名のパーサは後から登録したもので上書きする
+func RegisterEventParser(parser EventParser) {
+	eventParsers[parser.Event()] = parser
+}
+
+// SupportedEvents 対応しているイベント名とアクション一覧を取得する
+func SupportedEvents() map[string][]string {
+	events := map[string][]string{}
+	for event, parser := range eventParsers {
+		events[event] = parser.Actions()
+	}
+	return events
+}
+
+// summaryParser EventSummaryのパース関数をEventParserとして扱うためのアダプタ
+type summaryParser struct {
+	event   string
+	actions []string
+	parse   func(summary *EventSummary, payload []byte) error
+}
+
+func (p summaryParser) Event() string {
+	return p.event
+}
+
+func (p summaryParser) Actions() []string {
+	return p.actions
+}
+
+func (p summaryParser) Parse(payload []byte) ([]EventSummary, error) {
+	summary := EventSummary{}
+	err := p.parse(&summary, payload)
+	if err != nil {
+		return nil, err
+	}
+	return []EventSummary{summary}, nil
+}
+
+// parseAction ペイロードのアクションを取得する
+func parseAction(payload []byte) string {
+	evt := struct {
+		Action string `json:"action"`
+	}{}
+	json.Unmarshal(payload, &evt)
+	return evt.Action
+}
+
+// handlesAction パーサが対象とするアクションかどうか
+func handlesAction(parser EventParser, action string) bool {
+	actions := parser.Actions()
+	if len(actions) == 0 {
+		return true
+	}
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseEventSummaries 登録済みのパーサでgithubイベントサマリ一覧を生成する
+func ParseEventSummaries(hc HookContext) ([]EventSummary, error) {
+	parser, ok := eventParsers[hc.Event]
+	if !ok {
+		return nil, ErrUnhandledEvent
+	}
+	action := parseAction(hc.Payload)
+	if !handlesAction(parser, action) {
+		return nil, ErrUnhandledAction
+	}
+	summaries, err := parser.Parse(hc.Payload)
+	if e, ok := err.(*MissingFieldError); ok && e.Event == "" {
+		e.Event = hc.Event
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range summaries {
+		summaries[i].Event = hc.Event
+		if summaries[i].Action == "" {
+			summaries[i].Action = action
+		}
+	}
+	return summaries, nil
+}
+
+// ValidatePing pingイベントで登録されたイベントのうち未対応のものを取得する
+func ValidatePing(payload []byte) ([]string, error) {
+	evt := struct {
+		Hook *struct {
+			Events []string `json:"events"`
+		} `json:"hook"`
+	}{}
+	err := json.Unmarshal(payload, &evt)
+	if err != nil {
+		return nil, err
+	}
+	unsupported := []string{}
+	if evt.Hook == nil {
+		return unsupported, nil
+	}
+	for _, event := range evt.Hook.Events {
+		if _, ok := eventParsers[event]; !ok && event != "*" {
+			unsupported = append(unsupported, event)
+		}
+	}
+	sort.Strings(unsupported)
+	return unsupported, nil
+}
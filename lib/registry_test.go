@@ -0,0 +1,144 @@
+package lib
+
+import (
+	"testing"
+)
+
+type multiParser struct{}
+
+func (p multiParser) Event() string {
+	return "test_multi"
+}
+
+func (p multiParser) Actions() []string {
+	return []string{"done"}
+}
+
+func (p multiParser) Parse(payload []byte) ([]EventSummary, error) {
+	return []EventSummary{{Title: "a"}, {Title: "b"}}, nil
+}
+
+func TestParseEventSummaries(t *testing.T) {
+	payload := `{
+		"action": "opened",
+		"issue": {"title": "issue-title", "html_url": "url", "body": "body", "user": {"login": "user"}},
+		"repository": {"name": "repo-name"}
+	}`
+	summaries, err := ParseEventSummaries(HookContext{Event: "issues", Payload: []byte(payload)})
+	if err != nil {
+		t.Fatal("failed: parse issues", err)
+	}
+	if len(summaries) != 1 {
+		t.Fatal("failed: summaries", summaries)
+	}
+	if summaries[0].Event != "issues" || summaries[0].Action != "opened" {
+		t.Fatal("failed: Event/Action", summaries[0].Event, summaries[0].Action)
+	}
+	if summaries[0].Title != "issue-title" {
+		t.Fatal("failed: Title")
+	}
+
+	_, err = ParseEventSummaries(HookContext{Event: "issues", Payload: []byte(`{"action": "closed"}`)})
+	if err != ErrUnhandledAction {
+		t.Fatal("failed: unexpected error", err)
+	}
+
+	_, err = ParseEventSummaries(HookContext{Event: "unknown", Payload: []byte(`{}`)})
+	if err != ErrUnhandledEvent {
+		t.Fatal("failed: unexpected error", err)
+	}
+}
+
+func TestRegisterEventParser(t *testing.T) {
+	RegisterEventParser(multiParser{})
+	defer delete(eventParsers, "test_multi")
+
+	summaries, err := ParseEventSummaries(HookContext{Event: "test_multi", Payload: []byte(`{"action": "done"}`)})
+	if err != nil {
+		t.Fatal("failed: parse test_multi", err)
+	}
+	if len(summaries) != 2 || summaries[1].Title != "b" || summaries[1].Event != "test_multi" {
+		t.Fatal("failed: summaries", summaries)
+	}
+
+	summary := EventSummary{}
+	err = summary.ParseEventSummary(HookContext{Event: "test_multi", Payload: []byte(`{"action": "done"}`)})
+	if err != nil || summary.Title != "a" {
+		t.Fatal("failed: ParseEventSummary must use first summary", err, summary.Title)
+	}
+}
+
+func TestSupportedEvents(t *testing.T) {
+	events := SupportedEvents()
+	for _, event := range []string{"issues", "issue_comment", "pull_request", "pull_request_review", "pull_request_review_comment"} {
+		if _, ok := events[event]; !ok {
+			t.Fatal("failed: not registered: " + event)
+		}
+	}
+	if actions := events["issue_comment"]; len(actions) != 2 || actions[0] != "created" {
+		t.Fatal("failed: actions", actions)
+	}
+}
+
+func TestValidatePing(t *testing.T) {
+	payload := `{"zen": "zen", "hook": {"events": ["issues", "push", "*", "gollum"]}}`
+	unsupported, err := ValidatePing([]byte(payload))
+	if err != nil {
+		t.Fatal("failed: parse ping", err)
+	}
+	if len(unsupported) != 2 || unsupported[0] != "gollum" || unsupported[1] != "push" {
+		t.Fatal("failed: unsupported", unsupported)
+	}
+}
+
+func TestParseEventSummariesMissingFields(t *testing.T) {
+	for event, actions := range SupportedEvents() {
+		if len(actions) == 0 {
+			actions = []string{""}
+		}
+		for _, action := range actions {
+			payload := []byte(`{"action": "` + action + `", "ref_type": "branch"}`)
+			_, err := ParseEventSummaries(HookContext{Event: event, Payload: payload})
+			e, ok := err.(*MissingFieldError)
+			if !ok {
+				t.Fatal("failed: expected MissingFieldError: "+event+"."+action, err)
+			}
+			if e.Event != event || e.Field != "repository" && e.Field != "organization" {
+				t.Fatal("failed: invalid MissingFieldError: "+event+"."+action, e.Error())
+			}
+		}
+	}
+}
+
+func TestParseEventSummariesNullBody(t *testing.T) {
+	payload := `{
+		"action": "opened",
+		"issue": {"title": "issue-title", "html_url": "url", "body": null},
+		"repository": {"name": "repo-name"}
+	}`
+	summaries, err := ParseEventSummaries(HookContext{Event: "issues", Payload: []byte(payload)})
+	if err != nil {
+		t.Fatal("failed: parse null body", err)
+	}
+	if summaries[0].Comment != "" {
+		t.Fatal("failed: Comment", summaries[0].Comment)
+	}
+	if describe(summaries[0]) != "Issue opened" {
+		t.Fatal("failed: Description", describe(summaries[0]))
+	}
+}
+
+// 登録したアクションはどれもパーサが処理する (登録とパーサの分岐の食い違いを検出する)
+// アクションより先に必須項目を確かめるパーサもあるので、主な項目は空のオブジェクトで埋めておく
+func TestRegisteredActionsHandled(t *testing.T) {
+	fields := `"repository": {}, "organization": {}, "issue": {}, "pull_request": {}, "team": {}, "deployment": {}, "deployment_status": {}`
+	for event, actions := range SupportedEvents() {
+		for _, action := range actions {
+			payload := []byte(`{"action": "` + action + `", ` + fields + `}`)
+			_, err := eventParsers[event].Parse(payload)
+			if err == ErrUnhandledAction {
+				t.Fatal("failed: "+event+"."+action, err)
+			}
+		}
+	}
+}
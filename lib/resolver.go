@@ -0,0 +1,152 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// slackAPIURL Slack Web APIのURL
+var slackAPIURL = "https://slack.com/api"
+
+// slackClient Slack Web APIのクライアント
+// Webhookの処理中に呼ぶので、応答が遅い場合に待ち続けないようタイムアウトを設ける
+var slackClient = &http.Client{Timeout: 10 * time.Second}
+
+// slackMissTTL Slackユーザーが見つからなかったメールアドレスを再度問い合わせるまでの時間
+const slackMissTTL = time.Hour
+
+// ErrSlackUserNotFound メールアドレスに対応するSlackユーザーがいない
+var ErrSlackUserNotFound = errors.New("slack_user_not_found")
+
+// SlackResolver GithubアカウントのSlackユーザーIDをメールアドレスから解決する
+// 解決したIDはファイルにキャッシュし、再起動後も使い回す
+type SlackResolver struct {
+	token     string
+	cacheFile string
+	mu        sync.Mutex
+	// cache メールアドレスをキーとしたSlackユーザーID
+	cache map[string]string
+	// misses Slackユーザーが見つからなかったメールアドレスと問い合わせた時刻 (ファイルには保存しない)
+	misses map[string]time.Time
+	// now 現在時刻 (テストで差し替える)
+	now func() time.Time
+}
+
+// NewSlackResolver SlackResolverを生成する
+// キャッシュファイルがあれば読み込む
+func NewSlackResolver(token string, cacheFile string) *SlackResolver {
+	r := &SlackResolver{token: token, cacheFile: cacheFile, cache: map[string]string{}, misses: map[string]time.Time{}, now: time.Now}
+	if cacheFile == "" {
+		return r
+	}
+	b, err := ioutil.ReadFile(cacheFile)
+	if err == nil {
+		json.Unmarshal(b, &r.cache)
+	}
+	return r
+}
+
+// saveCache キャッシュをファイルへ書き出す
+func (r *SlackResolver) saveCache() error {
+	if r.cacheFile == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(r.cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.cacheFile, b, os.FileMode(0600))
+}
+
+// LookupByEmail メールアドレスからSlackユーザーIDを取得する
+// 見つからなかったメールアドレスはしばらく問い合わせない
+// 問い合わせの間はロックを外し、他のアカウントの解決を待たせない
+func (r *SlackResolver) LookupByEmail(email string) (string, error) {
+	r.mu.Lock()
+	if id, ok := r.cache[email]; ok {
+		r.mu.Unlock()
+		return id, nil
+	}
+	if missed, ok := r.misses[email]; ok && r.now().Sub(missed) < slackMissTTL {
+		r.mu.Unlock()
+		return "", ErrSlackUserNotFound
+	}
+	r.mu.Unlock()
+
+	id, err := r.lookupByEmail(email)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == ErrSlackUserNotFound {
+		r.misses[email] = r.now()
+	}
+	if err != nil {
+		return "", err
+	}
+	delete(r.misses, email)
+	r.cache[email] = id
+	return id, r.saveCache()
+}
+
+// lookupByEmail users.lookupByEmail を呼び出す
+func (r *SlackResolver) lookupByEmail(email string) (string, error) {
+	req, _ := http.NewRequest("GET", slackAPIURL+"/users.lookupByEmail?email="+url.QueryEscape(email), nil)
+	req.Header.Set("Authorization", "Bearer "+r.token)
+	res, err := slackClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body := struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		User  struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	}{}
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		return "", err
+	}
+	if !body.OK {
+		if body.Error == "users_not_found" {
+			return "", ErrSlackUserNotFound
+		}
+		return "", errors.New("slack_api_error: " + body.Error)
+	}
+	return body.User.ID, nil
+}
+
+// ResolveAccounts IDが未設定のアカウントのSlackユーザーIDを解決する
+// メールアドレスは設定を優先し、なければイベントを起こしたユーザーのものを使う
+func (r *SlackResolver) ResolveAccounts(accounts map[string]Account, summary EventSummary) {
+	if r.token == "" {
+		return
+	}
+	for key, account := range accounts {
+		if account.ID != "" {
+			continue
+		}
+		email := account.Email
+		if email == "" && key == "@"+summary.Actor.Login {
+			email = summary.Actor.Email
+		}
+		if email == "" {
+			continue
+		}
+		id, err := r.LookupByEmail(email)
+		if err != nil {
+			log.Println("failed: resolve Slack user: "+key, err)
+			continue
+		}
+		account.ID = "@" + id
+		accounts[key] = account
+	}
+}
@@ -0,0 +1,110 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLookupByEmail(t *testing.T) {
+	stub := newSlackStub(map[string]string{
+		"/users.lookupByEmail": `{"ok": true, "user": {"id": "U123"}}`,
+	})
+	defer stub.Close()
+	dir, _ := ioutil.TempDir("", "gosla2")
+	defer os.RemoveAll(dir)
+	cacheFile := filepath.Join(dir, "slack_users.json")
+
+	resolver := NewSlackResolver("xoxb-token", cacheFile)
+	id, err := resolver.LookupByEmail("user@example.com")
+	if err != nil || id != "U123" {
+		t.Fatal("failed: lookup", id, err)
+	}
+	requests := stub.Requests("/users.lookupByEmail")
+	if len(requests) != 1 {
+		t.Fatal("failed: request count", len(requests))
+	}
+	if requests[0].Query.Get("email") != "user@example.com" || requests[0].Header.Get("Authorization") != "Bearer xoxb-token" {
+		t.Fatal("failed: request", requests[0])
+	}
+
+	// 2回目以降はキャッシュを使う
+	resolver.LookupByEmail("user@example.com")
+	if len(stub.Requests("/users.lookupByEmail")) != 1 {
+		t.Fatal("failed: use cache")
+	}
+	// キャッシュファイルから読み込める
+	reloaded := NewSlackResolver("xoxb-token", cacheFile)
+	id, _ = reloaded.LookupByEmail("user@example.com")
+	if id != "U123" || len(stub.Requests("/users.lookupByEmail")) != 1 {
+		t.Fatal("failed: load cache file", id)
+	}
+}
+
+func TestLookupByEmailNotFound(t *testing.T) {
+	stub := newSlackStub(map[string]string{
+		"/users.lookupByEmail": `{"ok": false, "error": "users_not_found"}`,
+	})
+	defer stub.Close()
+	resolver := NewSlackResolver("xoxb-token", "")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	resolver.now = func() time.Time { return now }
+	_, err := resolver.LookupByEmail("unknown@example.com")
+	if err != ErrSlackUserNotFound {
+		t.Fatal("failed: not found", err)
+	}
+
+	// 見つからなかったメールアドレスはしばらく問い合わせない
+	now = now.Add(slackMissTTL - time.Minute)
+	if _, err := resolver.LookupByEmail("unknown@example.com"); err != ErrSlackUserNotFound {
+		t.Fatal("failed: cached miss", err)
+	}
+	if len(stub.Requests("/users.lookupByEmail")) != 1 {
+		t.Fatal("failed: cache miss")
+	}
+	now = now.Add(time.Minute)
+	resolver.LookupByEmail("unknown@example.com")
+	if len(stub.Requests("/users.lookupByEmail")) != 2 {
+		t.Fatal("failed: retry after TTL")
+	}
+}
+
+func TestResolveAccounts(t *testing.T) {
+	stub := newSlackStub(map[string]string{
+		"/users.lookupByEmail": `{"ok": true, "user": {"id": "U123"}}`,
+	})
+	defer stub.Close()
+	resolver := NewSlackResolver("xoxb-token", "")
+	accounts := map[string]Account{
+		"@a": Account{ID: "@aa"},
+		"@b": Account{Email: "b@example.com"},
+		"@c": Account{Channel: "ccc"},
+		"@d": Account{},
+	}
+	resolver.ResolveAccounts(accounts, EventSummary{Actor: Actor{Login: "c", Email: "c@example.com"}})
+	if accounts["@a"].ID != "@aa" {
+		t.Fatal("failed: keep configured ID", accounts["@a"])
+	}
+	if accounts["@b"].ID != "@U123" {
+		t.Fatal("failed: resolve by config email", accounts["@b"])
+	}
+	if accounts["@c"].ID != "@U123" || accounts["@c"].Channel != "ccc" {
+		t.Fatal("failed: resolve by actor email", accounts["@c"])
+	}
+	if accounts["@d"].ID != "" {
+		t.Fatal("failed: no email", accounts["@d"])
+	}
+	requests := stub.Requests("/users.lookupByEmail")
+	if len(requests) != 2 {
+		t.Fatal("failed: request count", len(requests))
+	}
+
+	// トークンがなければ何もしない
+	accounts = map[string]Account{"@b": Account{Email: "b@example.com"}}
+	NewSlackResolver("", "").ResolveAccounts(accounts, EventSummary{})
+	if accounts["@b"].ID != "" {
+		t.Fatal("failed: without token", accounts["@b"])
+	}
+}
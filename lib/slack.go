@@ -1,6 +1,9 @@
 package lib
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -12,14 +15,21 @@ import (
 var slackURL = "https://hooks.slack.com"
 var contentType = "application/x-www-form-urlencoded"
 
+// ErrNoDestination 送信先のチャンネルもSlackユーザーIDも分からない
+var ErrNoDestination = errors.New("no_destination")
+
 // sendToSlack Slackへのメッセージ送信
-func sendToSlack(path string, text string) (string, error) {
+func sendToSlack(path string, msg Message) (string, error) {
 	u, _ := url.ParseRequestURI(slackURL)
 	u.Path = path
 	urlStr := u.String()
 
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
 	data := url.Values{}
-	data.Set("payload", "{\"text\": \""+text+"\"}")
+	data.Set("payload", string(payload))
 
 	client := &http.Client{}
 	req, _ := http.NewRequest("POST", urlStr, strings.NewReader(data.Encode()))
@@ -34,21 +44,197 @@ func sendToSlack(path string, text string) (string, error) {
 	return string(b), nil
 }
 
-// CreatePostText 投稿用テキストを生成する
-func CreatePostText(summary EventSummary) string {
-	text := fmt.Sprintf("*[%v] %v*", summary.RepositoryName, summary.Title)
-	text = fmt.Sprintf("%v\n%v", text, summary.URL)
-	text = fmt.Sprintf("%v\n> %v", text, summary.Description)
-	text = fmt.Sprintf("%v\n%v", text, summary.Comment)
+// kindNames 説明文に使う通知対象の種類の表示名
+var kindNames = map[string]string{
+	"issue":                 "Issue",
+	"pull_request":          "PullRequest",
+	"comment":               "Comment",
+	"review":                "Review",
+	"workflow":              "Workflow",
+	"check_suite":           "CheckSuite",
+	"check_run":             "CheckRun",
+	"commit_comment":        "Commit comment",
+	"discussion":            "Discussion",
+	"release":               "Release",
+	"dependabot_alert":      "Dependabot alert",
+	"code_scanning_alert":   "Code scanning alert",
+	"secret_scanning_alert": "Secret scanning alert",
+	"deployment":            "Deployment",
+	"milestone":             "Milestone",
+	"member":                "Member",
+	"membership":            "Membership",
+	"team":                  "Team",
+	"organization":          "Organization",
+	"branch":                "Branch",
+	"tag":                   "Tag",
+}
+
+// stateKinds アクションではなく状態を説明に使う種類
+var stateKinds = map[string]bool{
+	"workflow":    true,
+	"check_suite": true,
+	"check_run":   true,
+	"deployment":  true,
+}
+
+// describe サマリから説明文を組み立てる
+// ex) "Issue opened by: user", "Workflow failure on main by: user"
+func describe(summary EventSummary) string {
+	words := []string{kindNames[summary.Kind]}
+	if words[0] == "" {
+		words[0] = summary.Kind
+	}
+	if summary.Kind == "release" {
+		words = append(words, summary.Ref)
+	}
+	if stateKinds[summary.Kind] {
+		words = append(words, summary.State)
+	} else {
+		words = append(words, summary.Action)
+	}
+	if summary.Action == "labeled" && len(summary.Labels) > 0 {
+		words = append(words, summary.Labels[0])
+	}
+	if summary.Severity != "" {
+		words = append(words, fmt.Sprintf("(%v)", summary.Severity))
+	}
+	if stateKinds[summary.Kind] {
+		target := summary.Ref
+		if summary.Kind == "deployment" {
+			target = summary.Environment
+		}
+		words = append(words, "on", target)
+	}
+	text := strings.Join(words, " ")
+	if summary.Actor.Login != "" {
+		text = fmt.Sprintf("%v by: %v", text, summary.Actor.Login)
+	}
+	if summary.Path != "" {
+		text = fmt.Sprintf("%v on %v", text, fileLink(summary))
+	}
+	return text
+}
+
+// fileLink コメント対象のファイルへのリンクを生成する
+func fileLink(summary EventSummary) string {
+	location := summary.Path
+	fileURL := fmt.Sprintf("%v/blob/%v/%v", summary.RepositoryURL, summary.CommitID, summary.Path)
+	switch {
+	case summary.StartLine > 0 && summary.StartLine < summary.Line:
+		location = fmt.Sprintf("%v:%v-%v", location, summary.StartLine, summary.Line)
+		fileURL = fmt.Sprintf("%v#L%v-L%v", fileURL, summary.StartLine, summary.Line)
+	case summary.Line > 0:
+		location = fmt.Sprintf("%v:%v", location, summary.Line)
+		fileURL = fmt.Sprintf("%v#L%v", fileURL, summary.Line)
+	}
+	return fmt.Sprintf("<%v|%v>", fileURL, location)
+}
+
+// diffContextLines コードの文脈として表示する差分の行数
+const diffContextLines = 4
+
+// codeContext レビューコメント対象の差分の末尾をコードブロックにする
+// 複数行へのコメントでは対象の行数分を表示する
+func codeContext(diffHunk string, startLine int, line int) string {
+	if diffHunk == "" {
+		return ""
+	}
+	lines := []string{}
+	for _, l := range strings.Split(strings.TrimRight(diffHunk, "\n"), "\n") {
+		if !strings.HasPrefix(l, "@@") {
+			lines = append(lines, l)
+		}
+	}
+	count := diffContextLines
+	if startLine > 0 && line-startLine+1 > count {
+		count = line - startLine + 1
+	}
+	if len(lines) > count {
+		lines = lines[len(lines)-count:]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "```\n" + mrkdwnEscaper.Replace(strings.Join(lines, "\n")) + "\n```"
+}
+
+// repositoryLabel 投稿に表示するリポジトリ名
+// 同名のリポジトリを区別できるよう、基本は "owner/repo" で表示する
+func repositoryLabel(summary EventSummary, short bool) string {
+	if short || summary.RepositoryFullName == "" {
+		return summary.RepositoryName
+	}
+	return summary.RepositoryFullName
+}
+
+// CreatePostText 組み込みのレイアウトで投稿用テキストを生成する
+// 設定のテンプレートを使う場合は Renderer を使う
+func CreatePostText(summary EventSummary, conf Config) string {
+	renderer, _ := NewRenderer(Config{ShortRepositoryName: conf.ShortRepositoryName})
+	text, _ := renderer.Render(summary)
 	return text
 }
 
-// PostToAccounts Slackアカウント宛に送信
+// callSlackAPI トークンを使ってSlack Web APIを呼び出す
+func callSlackAPI(token string, method string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, _ := http.NewRequest("POST", slackAPIURL+"/"+method, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := slackClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body := struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}{}
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		return err
+	}
+	if !body.OK {
+		return errors.New("slack_api_error: " + body.Error)
+	}
+	return nil
+}
+
+// postMessage chat.postMessage でメッセージを送信する
+// channel にユーザーIDを指定するとDMになる
+func postMessage(token string, channel string, msg Message) error {
+	msg.Channel = channel
+	return callSlackAPI(token, "chat.postMessage", msg)
+}
+
+// sendToAccount アカウントへ送信する
+// Webhookのチャンネルがなければ、トークンを使ってSlackユーザーへDMを送る
+func sendToAccount(account Account, msg Message, conf Config) error {
+	if account.Channel != "" {
+		_, err := sendToSlack(account.Channel, msg)
+		return err
+	}
+	if account.ID == "" || conf.SlackToken == "" {
+		return ErrNoDestination
+	}
+	return postMessage(conf.SlackToken, strings.TrimPrefix(account.ID, "@"), msg)
+}
+
+// PostToAccounts Slackアカウント宛にテキストを送信
+func PostToAccounts(text string, accounts map[string]Account, conf Config) {
+	PostMessageToAccounts(Message{Text: text}, accounts, conf)
+}
+
+// PostMessageToAccounts Slackアカウント宛にメッセージを送信
 // 送信エラーは無視して続行
-func PostToAccounts(text string, accounts map[string]Account) {
+func PostMessageToAccounts(msg Message, accounts map[string]Account, conf Config) {
 	for key, account := range accounts {
 		log.Println("start: send to Slack: " + key + ", channel: " + account.Channel)
-		_, err := sendToSlack(account.Channel, text)
+		err := sendToAccount(account, msg, conf)
 		if err != nil {
 			log.Println("failed: send to Slack: "+key, err)
 		} else {
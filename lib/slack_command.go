@@ -0,0 +1,188 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrNotLinked Githubアカウントと連携していない
+	ErrNotLinked = errors.New("not_linked")
+	// ErrAlreadyLinked Githubアカウントが別のSlackユーザーと連携済み
+	ErrAlreadyLinked = errors.New("already_linked")
+	// ErrInvalidLogin Githubアカウント名の形式ではない
+	ErrInvalidLogin = errors.New("invalid_login")
+	// ErrUnverifiedLink Githubアカウントの持ち主か確かめられない
+	ErrUnverifiedLink = errors.New("unverified_link")
+)
+
+// githubLoginPattern Githubアカウント名 (英数字とハイフン、39文字まで)
+var githubLoginPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]{0,38}$`)
+
+// LinkVerifier SlackユーザーがGithubアカウントの持ち主か確かめる
+type LinkVerifier func(login string, slackUserID string) error
+
+// NewLinkVerifier Githubの公開メールアドレスに対応するSlackユーザーかどうかで確かめる LinkVerifier を生成する
+func NewLinkVerifier(conf Config, resolver *SlackResolver) LinkVerifier {
+	return func(login string, slackUserID string) error {
+		authorization := ""
+		if conf.GithubToken != "" {
+			authorization = "token " + conf.GithubToken
+		}
+		b, err := githubRequest("GET", "/users/"+login, authorization, nil)
+		if err != nil {
+			return err
+		}
+		user := struct {
+			Email string `json:"email"`
+		}{}
+		if err := json.Unmarshal(b, &user); err != nil {
+			return err
+		}
+		if user.Email == "" {
+			return ErrUnverifiedLink
+		}
+		id, err := resolver.LookupByEmail(user.Email)
+		if err == ErrSlackUserNotFound {
+			return ErrUnverifiedLink
+		}
+		if err != nil {
+			return err
+		}
+		if id != slackUserID {
+			return ErrUnverifiedLink
+		}
+		return nil
+	}
+}
+
+// linkedInConfig 設定で別のSlackユーザーに割り当てたGithubアカウントかどうか
+func linkedInConfig(login string, slackUserID string, conf Config) bool {
+	for key, account := range conf.Accounts {
+		if strings.EqualFold(key, "@"+login) && account.ID != "" && account.ID != "@"+slackUserID {
+			return true
+		}
+	}
+	return false
+}
+
+// commandUsage スラッシュコマンドの使い方
+const commandUsage = "Usage:\n" +
+	"• `/gosla link &lt;github-login&gt;` link your GitHub account\n" +
+	"• `/gosla unlink` unlink your GitHub account\n" +
+	"• `/gosla status` show the linked account and muted repositories\n" +
+	"• `/gosla mute &lt;repo&gt;` stop notifications from a repository\n" +
+	"• `/gosla unmute [repo]` resume notifications (all repositories if omitted)"
+
+// SlashCommand Slackのスラッシュコマンドのリクエスト
+type SlashCommand struct {
+	Command     string
+	Text        string
+	UserID      string
+	UserName    string
+	ResponseURL string
+}
+
+// ParseSlashCommand スラッシュコマンドのリクエストをパースする
+func ParseSlashCommand(body []byte) (SlashCommand, error) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return SlashCommand{}, err
+	}
+	return SlashCommand{
+		Command:     values.Get("command"),
+		Text:        values.Get("text"),
+		UserID:      values.Get("user_id"),
+		UserName:    values.Get("user_name"),
+		ResponseURL: values.Get("response_url"),
+	}, nil
+}
+
+// RunCommand スラッシュコマンドを実行し、返信するテキストを返す
+// 連携するGithubアカウントは verify で持ち主か確かめる
+func (s *Store) RunCommand(cmd SlashCommand, conf Config, verify LinkVerifier) string {
+	args := strings.Fields(cmd.Text)
+	if len(args) == 0 {
+		return commandUsage
+	}
+	arg := ""
+	if len(args) > 1 {
+		arg = strings.TrimPrefix(args[1], "@")
+	}
+	switch args[0] {
+	case "link":
+		if arg == "" {
+			return commandUsage
+		}
+		if err := s.link(arg, cmd.UserID, conf, verify); err != nil {
+			return linkError(arg, err)
+		}
+		return fmt.Sprintf("Linked to GitHub account @%v", mrkdwnEscaper.Replace(arg))
+	case "unlink":
+		key, err := s.Unlink(cmd.UserID)
+		if err != nil {
+			return "failed: " + err.Error()
+		}
+		if key == "" {
+			return "Not linked to any GitHub account"
+		}
+		return fmt.Sprintf("Unlinked from GitHub account %v", key)
+	case "status":
+		text := "Not linked to any GitHub account"
+		if login, ok := s.GithubLogin(cmd.UserID, conf); ok {
+			text = fmt.Sprintf("Linked to GitHub account @%v", login)
+		}
+		if muted := s.Muted(cmd.UserID); len(muted) > 0 {
+			text = fmt.Sprintf("%v\nMuted: %v", text, mrkdwnEscaper.Replace(strings.Join(muted, ", ")))
+		}
+		return text
+	case "mute":
+		if arg == "" {
+			return commandUsage
+		}
+		if err := s.Mute(cmd.UserID, arg); err != nil {
+			return "failed: " + err.Error()
+		}
+		return fmt.Sprintf("Muted %v", mrkdwnEscaper.Replace(arg))
+	case "unmute":
+		if err := s.Unmute(cmd.UserID, arg); err != nil {
+			return "failed: " + err.Error()
+		}
+		if arg == "" {
+			return "Unmuted all repositories"
+		}
+		return fmt.Sprintf("Unmuted %v", mrkdwnEscaper.Replace(arg))
+	}
+	return commandUsage
+}
+
+// link 持ち主か確かめてからGithubアカウントと連携する
+func (s *Store) link(login string, slackUserID string, conf Config, verify LinkVerifier) error {
+	if !githubLoginPattern.MatchString(login) {
+		return ErrInvalidLogin
+	}
+	if linkedInConfig(login, slackUserID, conf) {
+		return ErrAlreadyLinked
+	}
+	if err := verify(login, slackUserID); err != nil {
+		return err
+	}
+	return s.Link(login, slackUserID)
+}
+
+// linkError 連携の失敗を返信用のテキストにする
+func linkError(login string, err error) string {
+	switch err {
+	case ErrInvalidLogin:
+		return "Invalid GitHub login"
+	case ErrAlreadyLinked:
+		return fmt.Sprintf("GitHub account @%v is already linked to another Slack user", login)
+	case ErrUnverifiedLink:
+		return fmt.Sprintf("Could not verify GitHub account @%v. Set the public email of your GitHub profile to the email of your Slack account", login)
+	}
+	return "failed: " + err.Error()
+}
@@ -0,0 +1,100 @@
+package lib
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseSlashCommand(t *testing.T) {
+	body := "command=%2Fgosla&text=link+miyanokomiya&user_id=U1&user_name=miya&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2F1"
+	cmd, err := ParseSlashCommand([]byte(body))
+	if err != nil {
+		t.Fatal("failed: parse", err)
+	}
+	if cmd.Command != "/gosla" || cmd.Text != "link miyanokomiya" || cmd.UserID != "U1" || cmd.ResponseURL != "https://hooks.slack.com/commands/1" {
+		t.Fatal("failed: parse", cmd)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	store, _ := NewStore("")
+	verified := func(login string, slackUserID string) error { return nil }
+	type fromTo struct {
+		text string
+		to   string
+	}
+	// 順に実行する
+	table := []fromTo{
+		{"", "Usage:"},
+		{"status", "Not linked to any GitHub account"},
+		{"link @miyanokomiya", "Linked to GitHub account @miyanokomiya"},
+		{"mute org/repo", "Muted org/repo"},
+		{"status", "Linked to GitHub account @miyanokomiya\nMuted: org/repo"},
+		{"mute <!channel>", "Muted &lt;!channel&gt;"},
+		{"unmute", "Unmuted all repositories"},
+		{"unlink", "Unlinked from GitHub account @miyanokomiya"},
+		{"link <!channel>", "Invalid GitHub login"},
+		{"unknown", "Usage:"},
+	}
+	for _, fromTo := range table {
+		result := store.RunCommand(SlashCommand{Text: fromTo.text, UserID: "U1"}, Config{}, verified)
+		if !strings.HasPrefix(result, fromTo.to) {
+			t.Fatal("failed: "+fromTo.text, "expect: "+fromTo.to, "actual: "+result)
+		}
+	}
+
+	store.RunCommand(SlashCommand{Text: "link miyanokomiya", UserID: "U1"}, Config{}, verified)
+	result := store.RunCommand(SlashCommand{Text: "link miyanokomiya", UserID: "U2"}, Config{}, verified)
+	if result != "GitHub account @miyanokomiya is already linked to another Slack user" {
+		t.Fatal("failed: link taken account", result)
+	}
+}
+
+func TestRunCommandLinkOwnership(t *testing.T) {
+	store, _ := NewStore("")
+	conf := Config{Accounts: map[string]Account{"@miyanokomiya": Account{ID: "@UB54ALKE2"}}}
+	verified := func(login string, slackUserID string) error { return nil }
+	// 設定で別のSlackユーザーに割り当てたアカウントは連携できない
+	result := store.RunCommand(SlashCommand{Text: "link miyanokomiya", UserID: "U2"}, conf, verified)
+	if result != "GitHub account @miyanokomiya is already linked to another Slack user" {
+		t.Fatal("failed: link configured account", result)
+	}
+	// 持ち主と確かめられないアカウントは連携できない
+	unverified := func(login string, slackUserID string) error { return ErrUnverifiedLink }
+	result = store.RunCommand(SlashCommand{Text: "link other", UserID: "U2"}, conf, unverified)
+	if !strings.HasPrefix(result, "Could not verify GitHub account @other") {
+		t.Fatal("failed: unverified", result)
+	}
+	if _, _, ok := store.Linked("U2"); ok {
+		t.Fatal("failed: linked without verification")
+	}
+}
+
+func TestLinkVerifier(t *testing.T) {
+	defer githubStub(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/miyanokomiya" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"login": "miyanokomiya", "email": "miya@example.com"}`))
+	})()
+	stub := newSlackStub(map[string]string{
+		"/users.lookupByEmail": `{"ok": true, "user": {"id": "U1"}}`,
+	})
+	defer stub.Close()
+
+	verify := NewLinkVerifier(Config{}, NewSlackResolver("xoxb-token", ""))
+	if err := verify("miyanokomiya", "U1"); err != nil {
+		t.Fatal("failed: verify owner", err)
+	}
+	if err := verify("miyanokomiya", "U2"); err != ErrUnverifiedLink {
+		t.Fatal("failed: verify other user", err)
+	}
+	if requests := stub.Requests("/users.lookupByEmail"); requests[0].Query.Get("email") != "miya@example.com" {
+		t.Fatal("failed: lookup email", requests[0].Query)
+	}
+	if err := verify("unknown", "U1"); err == nil {
+		t.Fatal("failed: unknown login")
+	}
+}
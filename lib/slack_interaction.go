@@ -0,0 +1,201 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrUnhandledButton 対応していないボタンが押された
+var ErrUnhandledButton = errors.New("unhandled_button")
+
+// ReplyCallbackID Githubへ返信するメッセージショートカットとモーダルの callback_id
+const ReplyCallbackID = "gosla_reply"
+
+// 返信モーダルの入力欄
+const (
+	replyInputBlockID  = "reply"
+	replyInputActionID = "body"
+)
+
+// Interaction Slackのボタン操作やショートカット、モーダルの送信のリクエスト
+// APIAppID はリクエストを受けたアプリ、Message.AppID は操作されたメッセージを投稿したアプリ
+type Interaction struct {
+	Type       string `json:"type"`
+	APIAppID   string `json:"api_app_id"`
+	CallbackID string `json:"callback_id"`
+	TriggerID  string `json:"trigger_id"`
+	User       struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	ResponseURL string `json:"response_url"`
+	Channel     struct {
+		ID string `json:"id"`
+	} `json:"channel"`
+	Message struct {
+		Text   string `json:"text"`
+		BotID  string `json:"bot_id"`
+		AppID  string `json:"app_id"`
+		Blocks []struct {
+			BlockID string `json:"block_id"`
+		} `json:"blocks"`
+	} `json:"message"`
+	Actions []InteractionAction `json:"actions"`
+	View    struct {
+		AppID           string `json:"app_id"`
+		CallbackID      string `json:"callback_id"`
+		PrivateMetadata string `json:"private_metadata"`
+		State           struct {
+			Values map[string]map[string]struct {
+				Value string `json:"value"`
+			} `json:"values"`
+		} `json:"state"`
+	} `json:"view"`
+}
+
+// InteractionAction 押されたボタン
+type InteractionAction struct {
+	ActionID string `json:"action_id"`
+	Value    string `json:"value"`
+}
+
+// ParseInteraction Slackのボタン操作のリクエストをパースする
+// 本文はフォーム形式で、payload にJSONが入っている
+func ParseInteraction(body []byte) (Interaction, error) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return Interaction{}, err
+	}
+	in := Interaction{}
+	err = json.Unmarshal([]byte(values.Get("payload")), &in)
+	if err != nil {
+		return Interaction{}, err
+	}
+	return in, nil
+}
+
+// ReplySource 操作されたメッセージに埋め込んだ返信先
+// 他のユーザーやアプリが block_id を真似て投稿したメッセージは対象にしない
+func (in Interaction) ReplySource() (ReplySource, bool) {
+	if in.APIAppID == "" || in.Message.BotID == "" || in.Message.AppID != in.APIAppID {
+		return ReplySource{}, false
+	}
+	for _, block := range in.Message.Blocks {
+		if source, err := ParseReplySource(block.BlockID); err == nil {
+			return source, true
+		}
+	}
+	return ReplySource{}, false
+}
+
+// SubmittedReplySource 送信された返信モーダルの返信先
+// このアプリが開いたモーダルのみ受け付ける
+func (in Interaction) SubmittedReplySource() (ReplySource, error) {
+	if in.APIAppID == "" || in.View.AppID != in.APIAppID {
+		return ReplySource{}, ErrInvalidReplySource
+	}
+	return ParseReplySource(in.View.PrivateMetadata)
+}
+
+// InDirectMessage 操作されたメッセージがDMにあるか
+// DMのチャンネルIDは D で始まる
+func (in Interaction) InDirectMessage() bool {
+	return strings.HasPrefix(in.Channel.ID, "D")
+}
+
+// ReplyText 返信モーダルに入力された本文
+func (in Interaction) ReplyText() string {
+	return in.View.State.Values[replyInputBlockID][replyInputActionID].Value
+}
+
+// ReplyErrors モーダルの入力欄にエラーを表示するレスポンス
+func ReplyErrors(err error) map[string]interface{} {
+	return map[string]interface{}{
+		"response_action": "errors",
+		"errors":          map[string]string{replyInputBlockID: "failed: " + err.Error()},
+	}
+}
+
+// OpenReplyModal 返信モーダルを開く
+func OpenReplyModal(token string, triggerID string, source ReplySource) error {
+	if token == "" {
+		return ErrNoDestination
+	}
+	return callSlackAPI(token, "views.open", map[string]interface{}{
+		"trigger_id": triggerID,
+		"view":       NewReplyModal(source),
+	})
+}
+
+// ReplyBody Githubへ投稿する本文に、Slackから誰が返信したかを添える
+// 返信した本人へ通知が飛ばないよう、Githubアカウントは @ を付けずに書く
+func ReplyBody(text string, login string) string {
+	return fmt.Sprintf("%v\n\n<sub>Replied from Slack by %v</sub>", strings.TrimSpace(text), login)
+}
+
+// RunAction 押されたボタンの操作を行い、元のメッセージに添える確認のテキストを返す
+func (s *Store) RunAction(slackUserID string, action InteractionAction, now time.Time) (string, error) {
+	switch action.ActionID {
+	case actionMuteThread:
+		if err := s.MuteThread(slackUserID, action.Value); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Muted %v", mrkdwnEscaper.Replace(action.Value)), nil
+	case actionSnooze:
+		d, err := time.ParseDuration(action.Value)
+		if err != nil {
+			return "", err
+		}
+		if err := s.Snooze(slackUserID, now.Add(d)); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Snoozed notifications for %v", mrkdwnEscaper.Replace(action.Value)), nil
+	case actionUnsubscribe:
+		if err := s.Unsubscribe(slackUserID, action.Value); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Unsubscribed from %v", mrkdwnEscaper.Replace(action.Value)), nil
+	case actionSeen:
+		return "Marked as seen", nil
+	}
+	return "", ErrUnhandledButton
+}
+
+// RespondToInteraction response_url へメッセージを送る
+// ReplaceOriginal を指定すると、ボタンを押したメッセージを置き換える
+func RespondToInteraction(responseURL string, msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	res, err := slackClient.Post(responseURL, "application/json; charset=utf-8", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return errors.New("slack_response_error: " + res.Status)
+	}
+	return nil
+}
+
+// BundleText 溜めておいた通知をひとつのメッセージにまとめる
+func BundleText(texts []string) string {
+	header := "*1 notification while you were away*"
+	if len(texts) > 1 {
+		header = fmt.Sprintf("*%v notifications while you were away*", len(texts))
+	}
+	return truncate(slackTextLimit, strings.Join(append([]string{header}, texts...), "\n\n"))
+}
+
+// PostBundle 溜めておいた通知をまとめて送る
+// 送れなかった場合に溜め直せるよう、送信エラーを返す
+func PostBundle(held HeldNotifications, conf Config) error {
+	return sendToAccount(held.Account, Message{Text: BundleText(held.Texts)}, conf)
+}
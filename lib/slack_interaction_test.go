@@ -0,0 +1,146 @@
+package lib
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInteraction(t *testing.T) {
+	payload := `{"type":"block_actions","user":{"id":"U1"},"response_url":"https://hooks.slack.com/actions/1","message":{"text":"text"},"actions":[{"action_id":"snooze","value":"2h"}]}`
+	in, err := ParseInteraction([]byte("payload=" + url.QueryEscape(payload)))
+	if err != nil {
+		t.Fatal("failed: parse", err)
+	}
+	if in.Type != "block_actions" || in.User.ID != "U1" || in.ResponseURL != "https://hooks.slack.com/actions/1" || in.Message.Text != "text" {
+		t.Fatal("failed: parse", in)
+	}
+	if len(in.Actions) != 1 || in.Actions[0].ActionID != "snooze" || in.Actions[0].Value != "2h" {
+		t.Fatal("failed: parse actions", in.Actions)
+	}
+	if _, err := ParseInteraction([]byte("payload=")); err == nil {
+		t.Fatal("failed: empty payload")
+	}
+}
+
+func TestInteractionReply(t *testing.T) {
+	payload := `{"type":"message_action","api_app_id":"A1","callback_id":"gosla_reply","trigger_id":"T1","message":{"text":"text","bot_id":"B1","app_id":"A1","blocks":[{"block_id":"abc"},{"block_id":"gosla_reply:org/repo:12:34"}]}}`
+	in, _ := ParseInteraction([]byte("payload=" + url.QueryEscape(payload)))
+	source, ok := in.ReplySource()
+	if !ok || source != (ReplySource{Repository: "org/repo", Number: 12, ReviewThreadID: 34}) {
+		t.Fatal("failed: reply source", source)
+	}
+	// このアプリが投稿したメッセージ以外は block_id があっても返信できない
+	other := in
+	other.Message.AppID = "A2"
+	if _, ok := other.ReplySource(); ok {
+		t.Fatal("failed: message from other app")
+	}
+	other = in
+	other.Message.BotID = ""
+	other.Message.AppID = ""
+	if _, ok := other.ReplySource(); ok {
+		t.Fatal("failed: message from user")
+	}
+	in.Message.Blocks = in.Message.Blocks[:1]
+	if _, ok := in.ReplySource(); ok {
+		t.Fatal("failed: no reply source")
+	}
+
+	payload = `{"type":"view_submission","api_app_id":"A1","view":{"app_id":"A1","callback_id":"gosla_reply","private_metadata":"gosla_reply:org/repo:12:0","state":{"values":{"reply":{"body":{"type":"plain_text_input","value":"LGTM"}}}}}}`
+	in, _ = ParseInteraction([]byte("payload=" + url.QueryEscape(payload)))
+	if in.View.CallbackID != ReplyCallbackID || in.ReplyText() != "LGTM" {
+		t.Fatal("failed: view submission", in.View)
+	}
+	if source, err := in.SubmittedReplySource(); err != nil || source != (ReplySource{Repository: "org/repo", Number: 12}) {
+		t.Fatal("failed: submitted reply source", source, err)
+	}
+	in.View.AppID = "A2"
+	if _, err := in.SubmittedReplySource(); err != ErrInvalidReplySource {
+		t.Fatal("failed: view from other app", err)
+	}
+}
+
+func TestReplyBody(t *testing.T) {
+	if body := ReplyBody(" LGTM\n", "miyanokomiya"); body != "LGTM\n\n<sub>Replied from Slack by miyanokomiya</sub>" {
+		t.Fatal("failed: reply body", body)
+	}
+}
+
+func TestOpenReplyModal(t *testing.T) {
+	stub := newSlackStub(map[string]string{})
+	defer stub.Close()
+	err := OpenReplyModal("xoxb-token", "T1", ReplySource{Repository: "org/repo", Number: 12})
+	if err != nil {
+		t.Fatal("failed: open modal", err)
+	}
+	requests := stub.Requests("/views.open")
+	if len(requests) != 1 || !strings.Contains(requests[0].Body, `"trigger_id":"T1"`) || !strings.Contains(requests[0].Body, `"private_metadata":"gosla_reply:org/repo:12:0"`) {
+		t.Fatal("failed: open modal request", requests)
+	}
+	if err := OpenReplyModal("", "T1", ReplySource{}); err != ErrNoDestination {
+		t.Fatal("failed: no token", err)
+	}
+}
+
+func TestRunAction(t *testing.T) {
+	store, _ := NewStore("")
+	now := time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)
+	type fromTo struct {
+		action InteractionAction
+		to     string
+	}
+	list := []fromTo{
+		fromTo{action: InteractionAction{ActionID: "mute_thread", Value: "org/repo#12"}, to: "Muted org/repo#12"},
+		fromTo{action: InteractionAction{ActionID: "snooze", Value: "2h"}, to: "Snoozed notifications for 2h"},
+		fromTo{action: InteractionAction{ActionID: "unsubscribe", Value: "org/repo"}, to: "Unsubscribed from org/repo"},
+		fromTo{action: InteractionAction{ActionID: "seen"}, to: "Marked as seen"},
+	}
+	for _, ft := range list {
+		text, err := store.RunAction("U1", ft.action, now)
+		if err != nil || text != ft.to {
+			t.Fatal("failed: run action", ft, text, err)
+		}
+	}
+	state := store.users["U1"]
+	if !contains(state.MutedThreads, "org/repo#12") || !contains(state.Unsubscribed, "org/repo") || !state.SnoozedUntil.Equal(now.Add(2*time.Hour)) {
+		t.Fatal("failed: state", state)
+	}
+	if _, err := store.RunAction("U1", InteractionAction{ActionID: "unknown"}, now); err != ErrUnhandledButton {
+		t.Fatal("failed: unknown action", err)
+	}
+	if _, err := store.RunAction("U1", InteractionAction{ActionID: "snooze", Value: "later"}, now); err == nil {
+		t.Fatal("failed: invalid duration")
+	}
+}
+
+func TestRespondToInteraction(t *testing.T) {
+	stub := newSlackStub(map[string]string{})
+	defer stub.Close()
+	in := Interaction{}
+	in.Channel.ID = "D1"
+	err := RespondToInteraction(stub.server.URL+"/actions/1", NewConfirmation(in, "Marked as seen"))
+	if err != nil {
+		t.Fatal("failed: respond", err)
+	}
+	requests := stub.Requests("/actions/1")
+	if len(requests) != 1 || !strings.Contains(requests[0].Body, `"replace_original":true`) {
+		t.Fatal("failed: respond request", requests)
+	}
+}
+
+func TestBundleText(t *testing.T) {
+	if text := BundleText([]string{"a"}); text != "*1 notification while you were away*\n\na" {
+		t.Fatal("failed: bundle one", text)
+	}
+	if text := BundleText([]string{"a", "b"}); text != "*2 notifications while you were away*\n\na\n\nb" {
+		t.Fatal("failed: bundle", text)
+	}
+}
+
+func TestPostBundle(t *testing.T) {
+	if err := PostBundle(HeldNotifications{Account: Account{ID: "@U1"}, Texts: []string{"a"}}, Config{}); err != ErrNoDestination {
+		t.Fatal("failed: no destination", err)
+	}
+}
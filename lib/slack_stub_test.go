@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+)
+
+// stubRequest スタブが受け取ったリクエスト
+type stubRequest struct {
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   string
+}
+
+// slackStub Slackの代わりに応答するテスト用サーバ
+// Web API と Incoming Webhook の向き先を差し替え、Close で元に戻す
+type slackStub struct {
+	server *httptest.Server
+	mu     sync.Mutex
+	// responses パスをキーとした応答のJSON (未登録のパスは {"ok": true})
+	responses map[string]string
+	requests  []stubRequest
+	apiURL    string
+	hookURL   string
+}
+
+// newSlackStub テスト用のSlackサーバを起動する
+func newSlackStub(responses map[string]string) *slackStub {
+	stub := &slackStub{responses: responses, apiURL: slackAPIURL, hookURL: slackURL}
+	stub.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		stub.mu.Lock()
+		stub.requests = append(stub.requests, stubRequest{Path: r.URL.Path, Query: r.URL.Query(), Header: r.Header, Body: string(body)})
+		res, ok := stub.responses[r.URL.Path]
+		stub.mu.Unlock()
+		if !ok {
+			res = `{"ok": true}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(res))
+	}))
+	slackAPIURL = stub.server.URL
+	slackURL = stub.server.URL
+	return stub
+}
+
+// Close サーバを止めて向き先を元に戻す
+func (stub *slackStub) Close() {
+	stub.server.Close()
+	slackAPIURL = stub.apiURL
+	slackURL = stub.hookURL
+}
+
+// Requests パスに届いたリクエスト一覧
+func (stub *slackStub) Requests(path string) []stubRequest {
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	requests := []stubRequest{}
+	for _, req := range stub.requests {
+		if req.Path == path {
+			requests = append(requests, req)
+		}
+	}
+	return requests
+}
+
+// hexHMACSHA256 テスト用にHMAC-SHA256の署名を生成する
+func hexHMACSHA256(secret string, message string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
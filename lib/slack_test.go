@@ -15,26 +15,70 @@ func TestCreatePostText(t *testing.T) {
 				RepositoryName: "repo",
 				Title:          "tit",
 				URL:            "url",
-				Description:    "desc",
+				Kind:           "issue",
+				Action:         "opened",
+				Actor:          Actor{Login: "user"},
 				Comment:        "comm",
 			},
-			to: "*[repo] tit*\nurl\n> desc\ncomm",
+			to: "*[repo] tit*\nurl\n> Issue opened by: user\ncomm",
 		},
 		"case2": fromTo{
 			from: EventSummary{
 				RepositoryName: "repo aaaa",
 				Title:          "tit",
 				URL:            "url",
-				Description:    "desc",
+				Kind:           "workflow",
+				Action:         "completed",
+				State:          "failure",
+				Ref:            "main",
 				Comment:        "comm\naaa\naaaeee",
 			},
-			to: "*[repo aaaa] tit*\nurl\n> desc\ncomm\naaa\naaaeee",
+			to: "*[repo aaaa] tit*\nurl\n> Workflow failure on main\ncomm\naaa\naaaeee",
 		},
 	}
 	for key, fromTo := range table {
-		result := CreatePostText(fromTo.from)
+		result := CreatePostText(fromTo.from, Config{})
 		if result != fromTo.to {
 			t.Fatal("failed: "+key, "expect: "+fromTo.to, "actual: "+result)
 		}
 	}
 }
+
+func TestCreatePostTextRepositoryName(t *testing.T) {
+	summary := EventSummary{
+		RepositoryName:     "api",
+		RepositoryFullName: "orgA/api",
+		Title:              "tit",
+		URL:                "url",
+		Kind:               "issue",
+		Action:             "opened",
+	}
+	result1 := CreatePostText(summary, Config{})
+	if result1 != "*[orgA/api] tit*\nurl\n> Issue opened\n" {
+		t.Fatal("failed: full name", result1)
+	}
+	result2 := CreatePostText(summary, Config{ShortRepositoryName: true})
+	if result2 != "*[api] tit*\nurl\n> Issue opened\n" {
+		t.Fatal("failed: short name", result2)
+	}
+}
+
+func TestPostToAccounts(t *testing.T) {
+	stub := newSlackStub(map[string]string{})
+	defer stub.Close()
+	accounts := map[string]Account{
+		"@a": Account{ID: "@U1", Channel: "/services/a"},
+		"@b": Account{ID: "@U2"},
+	}
+	PostToAccounts("text", accounts, Config{SlackToken: "xoxb-token"})
+	if len(stub.Requests("/services/a")) != 1 {
+		t.Fatal("failed: send to webhook")
+	}
+	requests := stub.Requests("/chat.postMessage")
+	if len(requests) != 1 {
+		t.Fatal("failed: send DM")
+	}
+	if requests[0].Body != `{"channel":"U2","text":"text"}` || requests[0].Header.Get("Authorization") != "Bearer xoxb-token" {
+		t.Fatal("failed: DM request", requests[0])
+	}
+}
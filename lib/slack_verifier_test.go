@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+// Slackのドキュメントにある署名の例
+const (
+	slackDocSecret    = "8f742231b10e8888abcd99yyyzzz85a5"
+	slackDocTimestamp = "1531420618"
+	slackDocBody      = "token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c"
+	slackDocSignature = "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503"
+)
+
+// slackDocVerifier ドキュメントの例の時刻で検証するSlackVerifier
+func slackDocVerifier(offset time.Duration) SlackVerifier {
+	return SlackVerifier{
+		Secret: slackDocSecret,
+		now: func() time.Time {
+			return time.Unix(1531420618, 0).Add(offset)
+		},
+	}
+}
+
+func TestVerifySlackSignature(t *testing.T) {
+	body := []byte(slackDocBody)
+	if !VerifySlackSignature(slackDocSecret, slackDocTimestamp, slackDocSignature, body) {
+		t.Fatal("failed: valid signature")
+	}
+	if VerifySlackSignature("other", slackDocTimestamp, slackDocSignature, body) {
+		t.Fatal("failed: invalid secret")
+	}
+	if VerifySlackSignature(slackDocSecret, "1531420619", slackDocSignature, body) {
+		t.Fatal("failed: invalid timestamp")
+	}
+	if VerifySlackSignature(slackDocSecret, slackDocTimestamp, slackDocSignature, []byte(slackDocBody+"&x=1")) {
+		t.Fatal("failed: invalid body")
+	}
+	if VerifySlackSignature("", slackDocTimestamp, slackDocSignature, body) {
+		t.Fatal("failed: empty secret")
+	}
+	if VerifySlackSignature(slackDocSecret, slackDocTimestamp, strings.TrimPrefix(slackDocSignature, "v0="), body) {
+		t.Fatal("failed: missing version")
+	}
+}
+
+func TestSlackVerifierVerify(t *testing.T) {
+	type fromTo struct {
+		offset    time.Duration
+		timestamp string
+		signature string
+		to        error
+	}
+	list := []fromTo{
+		fromTo{offset: 0, timestamp: slackDocTimestamp, signature: slackDocSignature, to: nil},
+		fromTo{offset: 4 * time.Minute, timestamp: slackDocTimestamp, signature: slackDocSignature, to: nil},
+		fromTo{offset: 6 * time.Minute, timestamp: slackDocTimestamp, signature: slackDocSignature, to: ErrStaleRequest},
+		fromTo{offset: -6 * time.Minute, timestamp: slackDocTimestamp, signature: slackDocSignature, to: ErrStaleRequest},
+		fromTo{offset: 0, timestamp: slackDocTimestamp, signature: "v0=00", to: ErrInvalidSignature},
+		fromTo{offset: 0, timestamp: "", signature: slackDocSignature, to: ErrInvalidSignature},
+	}
+	for _, ft := range list {
+		v := slackDocVerifier(ft.offset)
+		if err := v.Verify(ft.timestamp, ft.signature, []byte(slackDocBody)); err != ft.to {
+			t.Fatal("failed: verify", ft, err)
+		}
+	}
+
+	v := slackDocVerifier(10 * time.Minute)
+	v.Window = 15 * time.Minute
+	if err := v.Verify(slackDocTimestamp, slackDocSignature, []byte(slackDocBody)); err != nil {
+		t.Fatal("failed: custom window", err)
+	}
+}
+
+func TestSlackVerifierMiddleware(t *testing.T) {
+	api := rest.NewApi()
+	api.Use(slackDocVerifier(0))
+	router, err := rest.MakeRouter(
+		rest.Post("/slack/commands", func(w rest.ResponseWriter, r *rest.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			cmd, _ := ParseSlashCommand(body)
+			w.WriteJson(map[string]string{"user": cmd.UserName})
+		}),
+	)
+	if err != nil {
+		t.Fatal("failed: router", err)
+	}
+	api.SetApp(router)
+	server := httptest.NewServer(api.MakeHandler())
+	defer server.Close()
+
+	type fromTo struct {
+		signature string
+		status    int
+		body      string
+	}
+	list := []fromTo{
+		fromTo{signature: slackDocSignature, status: http.StatusOK, body: "roadrunner"},
+		fromTo{signature: "v0=00", status: http.StatusUnauthorized, body: "invalid_signature"},
+		fromTo{signature: "", status: http.StatusUnauthorized, body: "invalid_signature"},
+	}
+	for _, ft := range list {
+		req, _ := http.NewRequest("POST", server.URL+"/slack/commands", strings.NewReader(slackDocBody))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Slack-Request-Timestamp", slackDocTimestamp)
+		req.Header.Set("X-Slack-Signature", ft.signature)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal("failed: request", err)
+		}
+		b, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != ft.status || !strings.Contains(string(b), ft.body) {
+			t.Fatal("failed: response", ft, res.StatusCode, string(b))
+		}
+	}
+}
@@ -0,0 +1,442 @@
+package lib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LinkedAccount スラッシュコマンドで連携したアカウント
+type LinkedAccount struct {
+	SlackUserID string `json:"slack_user_id"`
+	// Verified Githubアカウントの持ち主か確かめて連携した
+	// 確かめずに連携した以前のものは通知や返信に使わない
+	Verified bool `json:"verified,omitempty"`
+	// Muted 以前の形式のミュート (読み込み時にSlackユーザーの状態へ移す)
+	Muted []string `json:"muted,omitempty"`
+}
+
+// SlackUserState 通知のボタンで設定したSlackユーザー毎の状態
+type SlackUserState struct {
+	// MutedThreads 通知を止めているIssueやプルリクエスト ("owner/repo#12")
+	MutedThreads []string `json:"muted_threads,omitempty"`
+	// Unsubscribed 通知を止めているリポジトリ
+	Unsubscribed []string `json:"unsubscribed,omitempty"`
+	// SnoozedUntil この時刻までの通知は Held に溜めておく
+	SnoozedUntil time.Time `json:"snoozed_until"`
+	// QuietUntil 静かな時間帯が明ける時刻 (それまでの通知は Held に溜めておく)
+	QuietUntil time.Time `json:"quiet_until"`
+	// Held 後でまとめて送る通知
+	Held []HeldMessage `json:"held,omitempty"`
+}
+
+// HeldMessage 溜めておいた通知
+type HeldMessage struct {
+	Text string `json:"text"`
+	// Channel 送るWebhookのチャンネル (空の場合はDMで送る)
+	Channel string `json:"channel,omitempty"`
+}
+
+// HeldNotifications 溜めておいた通知とその送信先
+// 送信先のチャンネル毎にまとめる
+type HeldNotifications struct {
+	Account Account
+	Texts   []string
+}
+
+// Store スラッシュコマンドやボタンで登録した情報をファイルに保存する
+// ファイルを指定しない場合はメモリ上にだけ保持する
+type Store struct {
+	file string
+	mu   sync.Mutex
+	// links Githubアカウント(@付き)をキーとした連携情報
+	links map[string]LinkedAccount
+	// users SlackユーザーIDをキーとした状態
+	users map[string]SlackUserState
+	// ci CIの種類・リポジトリ・ブランチ毎の直前の結果
+	ci map[string]string
+	// ciNotified 通知済みのCIの実行と状態 (同じ実行の重複通知を防ぐ)
+	ciNotified map[string]time.Time
+}
+
+// ciNotifiedTTL 通知済みのCIの実行を覚えておく期間
+const ciNotifiedTTL = 24 * time.Hour
+
+// storeData 保存ファイルの形式
+type storeData struct {
+	Links      map[string]LinkedAccount  `json:"links"`
+	Users      map[string]SlackUserState `json:"users,omitempty"`
+	CI         map[string]string         `json:"ci,omitempty"`
+	CINotified map[string]time.Time      `json:"ci_notified,omitempty"`
+}
+
+// NewStore Storeを生成する
+// 保存ファイルがあれば読み込む
+func NewStore(file string) (*Store, error) {
+	s := &Store{
+		file:       file,
+		links:      map[string]LinkedAccount{},
+		users:      map[string]SlackUserState{},
+		ci:         map[string]string{},
+		ciNotified: map[string]time.Time{},
+	}
+	if file == "" {
+		return s, nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := storeData{}
+	err = json.Unmarshal(b, &data)
+	if err != nil {
+		return nil, err
+	}
+	if data.Links != nil {
+		s.links = data.Links
+	}
+	if data.Users != nil {
+		s.users = data.Users
+	}
+	for key, link := range s.links {
+		if len(link.Muted) == 0 {
+			continue
+		}
+		state := s.users[link.SlackUserID]
+		for _, repository := range link.Muted {
+			if !contains(state.Unsubscribed, repository) {
+				state.Unsubscribed = append(state.Unsubscribed, repository)
+			}
+		}
+		s.users[link.SlackUserID] = state
+		link.Muted = nil
+		s.links[key] = link
+	}
+	if data.CI != nil {
+		s.ci = data.CI
+	}
+	if data.CINotified != nil {
+		s.ciNotified = data.CINotified
+	}
+	return s, nil
+}
+
+// save 保存ファイルへ書き出す
+func (s *Store) save() error {
+	if s.file == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(storeData{Links: s.links, Users: s.users, CI: s.ci, CINotified: s.ciNotified}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.file, b, os.FileMode(0600))
+}
+
+// findLink Slackユーザーが連携しているGithubアカウントを探す
+func (s *Store) findLink(slackUserID string) (string, LinkedAccount, bool) {
+	for key, link := range s.links {
+		if link.SlackUserID == slackUserID {
+			return key, link, true
+		}
+	}
+	return "", LinkedAccount{}, false
+}
+
+// Link GithubアカウントとSlackユーザーを連携する
+// 持ち主かどうかは呼び出し側で確かめておく
+// Slackユーザー毎に連携できるGithubアカウントはひとつ
+// 別のSlackユーザーが連携済みのGithubアカウントには連携できない (解除してもらう)
+func (s *Store) Link(login string, slackUserID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, link := range s.links {
+		if strings.EqualFold(key, "@"+login) && link.SlackUserID != slackUserID {
+			return ErrAlreadyLinked
+		}
+	}
+	if key, _, ok := s.findLink(slackUserID); ok {
+		delete(s.links, key)
+	}
+	s.links["@"+login] = LinkedAccount{SlackUserID: slackUserID, Verified: true}
+	return s.save()
+}
+
+// Unlink Slackユーザーの連携を解除し、解除したGithubアカウントを返す
+func (s *Store) Unlink(slackUserID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, _, ok := s.findLink(slackUserID)
+	if !ok {
+		return "", nil
+	}
+	delete(s.links, key)
+	return key, s.save()
+}
+
+// Linked Slackユーザーが連携しているGithubアカウント(@付き)と連携情報
+func (s *Store) Linked(slackUserID string) (string, LinkedAccount, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.findLink(slackUserID)
+}
+
+// GithubLogin Slackユーザーのものと確かめられたGithubアカウント(@なし)
+// 設定のアカウントを優先し、なければ持ち主を確かめて連携したものを使う
+func (s *Store) GithubLogin(slackUserID string, conf Config) (string, bool) {
+	for key, account := range conf.Accounts {
+		if account.ID == "@"+slackUserID {
+			return strings.TrimPrefix(key, "@"), true
+		}
+	}
+	key, link, ok := s.Linked(slackUserID)
+	if !ok || !link.Verified {
+		return "", false
+	}
+	return strings.TrimPrefix(key, "@"), true
+}
+
+// Muted Slackユーザーが通知を止めているリポジトリ
+func (s *Store) Muted(slackUserID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.users[slackUserID].Unsubscribed
+}
+
+// Mute リポジトリの通知を止める
+// 通知のボタンで止めたものと同じく、SlackユーザーIDで記録する
+func (s *Store) Mute(slackUserID string, repository string) error {
+	return s.Unsubscribe(slackUserID, repository)
+}
+
+// Unmute リポジトリの通知を再開する
+// リポジトリを指定しない場合は全て再開する
+func (s *Store) Unmute(slackUserID string, repository string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.users[slackUserID]
+	muted := []string{}
+	for _, m := range state.Unsubscribed {
+		if repository != "" && m != repository {
+			muted = append(muted, m)
+		}
+	}
+	state.Unsubscribed = muted
+	s.users[slackUserID] = state
+	return s.save()
+}
+
+// MergeAccounts 連携情報を設定のアカウント情報にまとめる
+// 設定にあるアカウントは設定を優先し、SlackユーザーIDがない場合だけ補う
+func (s *Store) MergeAccounts(conf *Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if conf.Accounts == nil {
+		conf.Accounts = map[string]Account{}
+	}
+	for key, link := range s.links {
+		if !link.Verified {
+			continue
+		}
+		account := conf.Accounts[key]
+		if account.ID == "" {
+			account.ID = "@" + link.SlackUserID
+		}
+		conf.Accounts[key] = account
+	}
+}
+
+// FilterMuted 通知を止めているアカウントを除く
+// ミュートはSlackユーザーIDで記録しているので、IDが分かるアカウントだけが対象になる
+func (s *Store) FilterMuted(accounts map[string]Account, summary EventSummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, account := range accounts {
+		state := s.users[strings.TrimPrefix(account.ID, "@")]
+		if contains(state.MutedThreads, summary.ThreadKey()) {
+			delete(accounts, key)
+			continue
+		}
+		for _, repository := range summary.repositoryKeys() {
+			if contains(state.Unsubscribed, repository) {
+				delete(accounts, key)
+			}
+		}
+	}
+}
+
+// MuteThread Issueやプルリクエストの通知を止める
+func (s *Store) MuteThread(slackUserID string, thread string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.users[slackUserID]
+	if !contains(state.MutedThreads, thread) {
+		state.MutedThreads = append(state.MutedThreads, thread)
+	}
+	s.users[slackUserID] = state
+	return s.save()
+}
+
+// Unsubscribe リポジトリの通知を止める
+// 連携していなくても使えるよう、SlackユーザーIDで記録する
+func (s *Store) Unsubscribe(slackUserID string, repository string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.users[slackUserID]
+	if !contains(state.Unsubscribed, repository) {
+		state.Unsubscribed = append(state.Unsubscribed, repository)
+	}
+	s.users[slackUserID] = state
+	return s.save()
+}
+
+// Snooze 指定の時刻まで通知を溜めておく
+func (s *Store) Snooze(slackUserID string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.users[slackUserID]
+	state.SnoozedUntil = until
+	s.users[slackUserID] = state
+	return s.save()
+}
+
+// Hold スヌーズ中や静かな時間帯のアカウントへの通知を溜め、送信先から除く
+// 急ぎの通知はスヌーズ中のみ溜め、静かな時間帯でもすぐに送る
+func (s *Store) Hold(accounts map[string]Account, text string, now time.Time, urgent bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	held := false
+	for key, account := range accounts {
+		id := strings.TrimPrefix(account.ID, "@")
+		if id == "" {
+			continue
+		}
+		state := s.users[id]
+		quiet := false
+		if !urgent {
+			if until, ok := account.QuietUntil(now); ok && until.After(state.QuietUntil) {
+				state.QuietUntil = until
+			}
+			quiet = now.Before(state.QuietUntil)
+		}
+		if !now.Before(state.SnoozedUntil) && !quiet {
+			continue
+		}
+		state.Held = append(state.Held, HeldMessage{Text: text, Channel: account.Channel})
+		s.users[id] = state
+		delete(accounts, key)
+		held = true
+	}
+	if !held {
+		return nil
+	}
+	return s.save()
+}
+
+// TakeDue スヌーズや静かな時間帯が明けたSlackユーザーの溜めた通知を取り出す
+// 送れなかった通知は Requeue で戻す
+func (s *Store) TakeDue(now time.Time) ([]HeldNotifications, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	due := []HeldNotifications{}
+	for id, state := range s.users {
+		if len(state.Held) == 0 || now.Before(state.SnoozedUntil) || now.Before(state.QuietUntil) {
+			continue
+		}
+		// チャンネル毎に、溜めた順を保ってまとめる
+		channels := map[string]int{}
+		for _, message := range state.Held {
+			i, ok := channels[message.Channel]
+			if !ok {
+				i = len(due)
+				channels[message.Channel] = i
+				due = append(due, HeldNotifications{Account: Account{ID: "@" + id, Channel: message.Channel}})
+			}
+			due[i].Texts = append(due[i].Texts, message.Text)
+		}
+		state.Held = nil
+		s.users[id] = state
+	}
+	if len(due) == 0 {
+		return due, nil
+	}
+	return due, s.save()
+}
+
+// Requeue 送れなかった通知を溜めておいた通知の先頭へ戻す
+func (s *Store) Requeue(held HeldNotifications) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := strings.TrimPrefix(held.Account.ID, "@")
+	state := s.users[id]
+	messages := []HeldMessage{}
+	for _, text := range held.Texts {
+		messages = append(messages, HeldMessage{Text: text, Channel: held.Account.Channel})
+	}
+	state.Held = append(messages, state.Held...)
+	s.users[id] = state
+	return s.save()
+}
+
+// RecordCI CIの結果を記録し、通知に使う状態を決める
+// 失敗はそのまま、成功は直前が失敗の場合のみ fixed として通知する
+// キャンセルやスキップなどの結果は記録せず、同じ実行の同じ状態は一度だけ通知する
+// (ひとつの実行が workflow_run, check_suite, check_run で重ねて届く)
+func (s *Store) RecordCI(summary EventSummary, now time.Time) (EventSummary, error) {
+	if summary.ciKey == "" {
+		return summary, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conclusion := summary.State
+	if conclusion != "success" && !isFailedConclusion(conclusion) {
+		return summary, ErrIgnoredEvent
+	}
+	prev := s.ci[summary.ciKey]
+	s.ci[summary.ciKey] = conclusion
+	state := ""
+	if isFailedConclusion(conclusion) {
+		state = conclusion
+	} else if isFailedConclusion(prev) {
+		state = "fixed"
+	}
+	if state != "" && summary.ciRunKey != "" {
+		for key, notified := range s.ciNotified {
+			if now.Sub(notified) > ciNotifiedTTL {
+				delete(s.ciNotified, key)
+			}
+		}
+		key := summary.RepositoryFullName + "@" + summary.ciRunKey + ":" + state
+		if _, ok := s.ciNotified[key]; ok {
+			state = ""
+		} else {
+			s.ciNotified[key] = now
+		}
+	}
+	err := s.save()
+	if state == "" {
+		return summary, ErrIgnoredEvent
+	}
+	// 保存に失敗しても通知はする
+	summary.State = state
+	return summary, err
+}
+
+// contains 一覧に含まれるか
+func contains(list []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,270 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoreLink(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gosla2")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "store.json")
+
+	store, err := NewStore(file)
+	if err != nil {
+		t.Fatal("failed: NewStore", err)
+	}
+	store.Link("a", "U1")
+	store.Link("b", "U1")
+	if key, _, ok := store.Linked("U1"); !ok || key != "@b" {
+		t.Fatal("failed: relink", key)
+	}
+	if err := store.Mute("U1", "org/repo"); err != nil {
+		t.Fatal("failed: Mute", err)
+	}
+	// 別のSlackユーザーが連携済みのアカウントは奪えない
+	if err := store.Link("b", "U2"); err != ErrAlreadyLinked {
+		t.Fatal("failed: link taken account", err)
+	}
+	if err := store.Link("B", "U2"); err != ErrAlreadyLinked {
+		t.Fatal("failed: link taken account ignoring case", err)
+	}
+	if _, _, ok := store.Linked("U2"); ok {
+		t.Fatal("failed: link taken account")
+	}
+
+	// 保存したファイルから読み込める
+	reloaded, err := NewStore(file)
+	if err != nil {
+		t.Fatal("failed: reload", err)
+	}
+	if key, _, ok := reloaded.Linked("U1"); !ok || key != "@b" {
+		t.Fatal("failed: reload", key)
+	}
+	if muted := reloaded.Muted("U1"); len(muted) != 1 || muted[0] != "org/repo" {
+		t.Fatal("failed: reload muted", muted)
+	}
+
+	// ミュートはSlackユーザーIDで記録するので、連携を解除しても残る
+	if key, _ := reloaded.Unlink("U1"); key != "@b" {
+		t.Fatal("failed: Unlink", key)
+	}
+	if _, _, ok := reloaded.Linked("U1"); ok {
+		t.Fatal("failed: Unlink")
+	}
+	if len(reloaded.Muted("U1")) != 1 {
+		t.Fatal("failed: keep muted after Unlink")
+	}
+	reloaded.Unmute("U1", "")
+	if muted := reloaded.Muted("U1"); len(muted) != 0 {
+		t.Fatal("failed: Unmute", muted)
+	}
+}
+
+func TestStoreLegacyLinks(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gosla2")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "store.json")
+	ioutil.WriteFile(file, []byte(`{"links": {"@a": {"slack_user_id": "U1", "muted": ["org/repo"]}}}`), 0600)
+
+	store, err := NewStore(file)
+	if err != nil {
+		t.Fatal("failed: NewStore", err)
+	}
+	// 以前のミュートはSlackユーザーの状態へ移す
+	if muted := store.Muted("U1"); len(muted) != 1 || muted[0] != "org/repo" {
+		t.Fatal("failed: migrate muted", muted)
+	}
+	// 持ち主を確かめていない連携は使わない
+	if _, ok := store.GithubLogin("U1", Config{}); ok {
+		t.Fatal("failed: unverified link")
+	}
+	conf := Config{}
+	store.MergeAccounts(&conf)
+	if _, ok := conf.Accounts["@a"]; ok {
+		t.Fatal("failed: merge unverified link", conf.Accounts)
+	}
+}
+
+func TestStoreGithubLogin(t *testing.T) {
+	store, _ := NewStore("")
+	store.Link("linked", "U2")
+	conf := Config{Accounts: map[string]Account{"@configured": Account{ID: "@U1"}}}
+	if login, ok := store.GithubLogin("U1", conf); !ok || login != "configured" {
+		t.Fatal("failed: configured account", login)
+	}
+	if login, ok := store.GithubLogin("U2", conf); !ok || login != "linked" {
+		t.Fatal("failed: linked account", login)
+	}
+	if _, ok := store.GithubLogin("U3", conf); ok {
+		t.Fatal("failed: unknown user")
+	}
+}
+
+func TestStoreMergeAccounts(t *testing.T) {
+	store, _ := NewStore("")
+	store.Link("a", "U1")
+	store.Link("b", "U2")
+	conf := Config{
+		Accounts: map[string]Account{
+			"@a": Account{ID: "@configured", Channel: "aaa"},
+			"@c": Account{ID: "@cc"},
+		},
+	}
+	store.MergeAccounts(&conf)
+	if conf.Accounts["@a"].ID != "@configured" || conf.Accounts["@a"].Channel != "aaa" {
+		t.Fatal("failed: keep config", conf.Accounts["@a"])
+	}
+	if conf.Accounts["@b"].ID != "@U2" {
+		t.Fatal("failed: add linked account", conf.Accounts["@b"])
+	}
+	if conf.Accounts["@c"].ID != "@cc" {
+		t.Fatal("failed: keep other account", conf.Accounts["@c"])
+	}
+}
+
+func TestStoreFilterMuted(t *testing.T) {
+	store, _ := NewStore("")
+	store.Link("a", "U1")
+	store.Mute("U1", "repo")
+	accounts := map[string]Account{"@a": Account{ID: "@U1"}, "@b": Account{ID: "@U2"}}
+	store.FilterMuted(accounts, EventSummary{RepositoryName: "repo", RepositoryFullName: "org/repo"})
+	if _, ok := accounts["@a"]; ok || len(accounts) != 1 {
+		t.Fatal("failed: filter muted", accounts)
+	}
+	accounts = map[string]Account{"@a": Account{ID: "@U1"}}
+	store.FilterMuted(accounts, EventSummary{RepositoryName: "other", RepositoryFullName: "org/other"})
+	if len(accounts) != 1 {
+		t.Fatal("failed: keep other repository", accounts)
+	}
+}
+
+func TestStoreFilterMutedByButton(t *testing.T) {
+	store, _ := NewStore("")
+	store.MuteThread("U1", "org/repo#1")
+	store.Unsubscribe("U2", "org/other")
+	summary := EventSummary{Event: "issue_comment", RepositoryName: "repo", RepositoryFullName: "org/repo", Number: 1}
+	accounts := map[string]Account{"@a": Account{ID: "@U1"}, "@b": Account{ID: "@U2"}}
+	store.FilterMuted(accounts, summary)
+	if _, ok := accounts["@a"]; ok || len(accounts) != 1 {
+		t.Fatal("failed: filter muted thread", accounts)
+	}
+	// 番号が同じでもワークフローやアラートは別物として扱う
+	for _, event := range []string{"workflow_run", "dependabot_alert", "milestone", "discussion"} {
+		accounts = map[string]Account{"@a": Account{ID: "@U1"}}
+		store.FilterMuted(accounts, EventSummary{Event: event, RepositoryName: "repo", RepositoryFullName: "org/repo", Number: 1})
+		if len(accounts) != 1 {
+			t.Fatal("failed: keep "+event, accounts)
+		}
+	}
+	summary.Number = 2
+	accounts = map[string]Account{"@a": Account{ID: "@U1"}}
+	store.FilterMuted(accounts, summary)
+	if len(accounts) != 1 {
+		t.Fatal("failed: keep other thread", accounts)
+	}
+	accounts = map[string]Account{"@b": Account{ID: "@U2"}}
+	store.FilterMuted(accounts, EventSummary{RepositoryName: "other", RepositoryFullName: "org/other"})
+	if len(accounts) != 0 {
+		t.Fatal("failed: filter unsubscribed", accounts)
+	}
+}
+
+func TestStoreHold(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gosla2")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "store.json")
+
+	store, _ := NewStore(file)
+	now := time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)
+	store.Snooze("U1", now.Add(2*time.Hour))
+	accounts := map[string]Account{"@a": Account{ID: "@U1"}, "@b": Account{ID: "@U2"}}
+	store.Hold(accounts, "first", now, false)
+	store.Hold(map[string]Account{"@a": Account{ID: "@U1"}}, "second", now.Add(time.Hour), true)
+	if _, ok := accounts["@a"]; ok || len(accounts) != 1 {
+		t.Fatal("failed: hold snoozed", accounts)
+	}
+
+	// 保存したファイルから読み込める
+	reloaded, _ := NewStore(file)
+	if due, _ := reloaded.TakeDue(now.Add(time.Hour)); len(due) != 0 {
+		t.Fatal("failed: still snoozed", due)
+	}
+	due, _ := reloaded.TakeDue(now.Add(2 * time.Hour))
+	if len(due) != 1 || due[0].Account.ID != "@U1" || len(due[0].Texts) != 2 || due[0].Texts[0] != "first" || due[0].Texts[1] != "second" {
+		t.Fatal("failed: take due", due)
+	}
+	if due, _ := reloaded.TakeDue(now.Add(3 * time.Hour)); len(due) != 0 {
+		t.Fatal("failed: take once", due)
+	}
+	accounts = map[string]Account{"@a": Account{ID: "@U1"}}
+	reloaded.Hold(accounts, "third", now.Add(3*time.Hour), false)
+	if len(accounts) != 1 {
+		t.Fatal("failed: send after snooze", accounts)
+	}
+}
+
+func TestStoreHoldQuietHours(t *testing.T) {
+	store, _ := NewStore("")
+	quiet := Account{ID: "@U1", Channel: "/services/a", Timezone: "Asia/Tokyo", QuietHours: "22:00-08:00"}
+	// 日本時間の 23:00
+	now := time.Date(2018, 1, 1, 14, 0, 0, 0, time.UTC)
+
+	accounts := map[string]Account{"@a": quiet, "@b": Account{ID: "@U2"}}
+	store.Hold(accounts, "first", now, false)
+	if _, ok := accounts["@a"]; ok || len(accounts) != 1 {
+		t.Fatal("failed: hold in quiet hours", accounts)
+	}
+	// 急ぎの通知は静かな時間帯でもすぐに送る
+	accounts = map[string]Account{"@a": quiet}
+	store.Hold(accounts, "urgent", now, true)
+	if len(accounts) != 1 {
+		t.Fatal("failed: send urgent", accounts)
+	}
+
+	// 日本時間の 07:59 まではまだ溜めておく
+	if due, _ := store.TakeDue(time.Date(2018, 1, 1, 22, 59, 0, 0, time.UTC)); len(due) != 0 {
+		t.Fatal("failed: still quiet", due)
+	}
+	due, _ := store.TakeDue(time.Date(2018, 1, 1, 23, 0, 0, 0, time.UTC))
+	if len(due) != 1 || due[0].Account.Channel != "/services/a" || len(due[0].Texts) != 1 || due[0].Texts[0] != "first" {
+		t.Fatal("failed: take due", due)
+	}
+}
+
+func TestStoreRequeue(t *testing.T) {
+	store, _ := NewStore("")
+	now := time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)
+	store.Snooze("U1", now.Add(time.Hour))
+	// 送信先のチャンネル毎にまとめる
+	store.Hold(map[string]Account{"@a": Account{ID: "@U1", Channel: "/services/a"}}, "first", now, false)
+	store.Hold(map[string]Account{"@a": Account{ID: "@U1", Channel: "/services/b"}}, "second", now, false)
+	store.Hold(map[string]Account{"@a": Account{ID: "@U1", Channel: "/services/a"}}, "third", now, false)
+	due, _ := store.TakeDue(now.Add(time.Hour))
+	if len(due) != 2 {
+		t.Fatal("failed: group by channel", due)
+	}
+	for _, held := range due {
+		if held.Account.Channel == "/services/a" && (len(held.Texts) != 2 || held.Texts[0] != "first" || held.Texts[1] != "third") {
+			t.Fatal("failed: channel a", held)
+		}
+		if held.Account.Channel == "/services/b" && (len(held.Texts) != 1 || held.Texts[0] != "second") {
+			t.Fatal("failed: channel b", held)
+		}
+	}
+
+	// 送れなかった通知は次に取り出せる
+	store.Snooze("U1", now.Add(2*time.Hour))
+	store.Hold(map[string]Account{"@a": Account{ID: "@U1", Channel: "/services/a"}}, "fifth", now.Add(time.Hour), false)
+	store.Requeue(due[0])
+	due, _ = store.TakeDue(now.Add(2 * time.Hour))
+	if len(due) != 1 || due[0].Account.Channel != "/services/a" {
+		t.Fatal("failed: requeue", due)
+	}
+	if len(due[0].Texts) != 3 || due[0].Texts[0] != "first" || due[0].Texts[1] != "third" || due[0].Texts[2] != "fifth" {
+		t.Fatal("failed: requeue order", due[0].Texts)
+	}
+}
@@ -0,0 +1,160 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+)
+
+// defaultTemplate 組み込みの投稿レイアウト
+// テンプレートを指定しない場合はこのレイアウトで投稿する
+const defaultTemplate = `*[{{repository .}}] {{.Title}}*
+{{.URL}}
+> {{describe .}}
+{{if .CodeContext}}{{.CodeContext}}
+{{end}}{{.Comment}}`
+
+// mrkdwnEscaper Slackのmrkdwnで制御文字となる記号のエスケープ
+var mrkdwnEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escapeSummary ユーザー由来の項目をmrkdwn向けにエスケープする
+// 本文のMarkdownはmrkdwnに変換し、ReplaceComment で埋め込んだメンションだけはそのまま残す
+func escapeSummary(summary EventSummary) EventSummary {
+	escape := mrkdwnEscaper.Replace
+	summary.RepositoryName = escape(summary.RepositoryName)
+	summary.RepositoryFullName = escape(summary.RepositoryFullName)
+	summary.RepositoryOwner = escape(summary.RepositoryOwner)
+	summary.RepositoryURL = escape(summary.RepositoryURL)
+	summary.Actor.Login = escape(summary.Actor.Login)
+	summary.Actor.AvatarURL = escape(summary.Actor.AvatarURL)
+	summary.Actor.Email = escape(summary.Actor.Email)
+	summary.State = escape(summary.State)
+	summary.Title = escape(summary.Title)
+	summary.URL = escape(summary.URL)
+	summary.Ref = escape(summary.Ref)
+	summary.HeadRef = escape(summary.HeadRef)
+	summary.CommitID = escape(summary.CommitID)
+	summary.Environment = escape(summary.Environment)
+	summary.Path = escape(summary.Path)
+	summary.Severity = escape(summary.Severity)
+	labels := []string{}
+	for _, label := range summary.Labels {
+		labels = append(labels, escape(label))
+	}
+	summary.Labels = labels
+	recipients := []string{}
+	for _, recipient := range summary.Recipients {
+		recipients = append(recipients, escape(recipient))
+	}
+	summary.Recipients = recipients
+
+	comment := summary.Comment
+	for i, mention := range summary.mentions {
+		comment = strings.Replace(comment, mention, mentionPlaceholder(i), -1)
+	}
+	if markdownKinds[summary.Kind] {
+		comment = toSlackMarkdown(comment)
+	} else {
+		comment = escape(comment)
+	}
+	for i, mention := range summary.mentions {
+		comment = strings.Replace(comment, mentionPlaceholder(i), mention, -1)
+	}
+	summary.Comment = comment
+	// レビューコメントはコードが分かるよう対象の差分を本文とは別に渡す
+	summary.CodeContext = codeContext(summary.DiffHunk, summary.StartLine, summary.Line)
+	summary.DiffHunk = escape(summary.DiffHunk)
+	return summary
+}
+
+// mentionPlaceholder エスケープの間メンションを退避しておく目印
+func mentionPlaceholder(i int) string {
+	return fmt.Sprintf("\x03%d\x03", i)
+}
+
+// truncate 文字数を超えた分を切り詰める
+// リンクやメンションの記法の途中では切らない
+func truncate(length int, text string) string {
+	if length < 0 || len([]rune(text)) <= length {
+		return text
+	}
+	return cutText(text, length) + "…"
+}
+
+// link Slackのリンク記法を生成する
+func link(url string, text string) string {
+	return fmt.Sprintf("<%v|%v>", url, text)
+}
+
+// emoji Slackの絵文字記法を生成する
+func emoji(name string) string {
+	return ":" + strings.Trim(name, ":") + ":"
+}
+
+// Renderer テンプレートから投稿用テキストを生成する
+type Renderer struct {
+	// templates "event.action" または "event" をキーとしたテンプレート
+	templates map[string]*template.Template
+	fallback  *template.Template
+	limit     BodyLimit
+}
+
+// NewRenderer 設定のテンプレートからRendererを生成する
+// テンプレートの構文や参照している項目が不正な場合はエラーとする
+func NewRenderer(conf Config) (*Renderer, error) {
+	funcs := template.FuncMap{
+		"repository": func(summary EventSummary) string {
+			return repositoryLabel(summary, conf.ShortRepositoryName)
+		},
+		"describe": describe,
+		"truncate": truncate,
+		"escape":   mrkdwnEscaper.Replace,
+		"link":     link,
+		"emoji":    emoji,
+	}
+	r := &Renderer{templates: map[string]*template.Template{}, limit: conf.BodyLimit}
+	fallback, err := template.New("default").Funcs(funcs).Parse(defaultTemplate)
+	if err != nil {
+		return nil, err
+	}
+	r.fallback = fallback
+	for key, text := range conf.Templates {
+		tmpl, err := template.New(key).Funcs(funcs).Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		// 存在しない項目の参照は実行時にしか分からないので空のサマリで試しておく
+		err = tmpl.Execute(ioutil.Discard, EventSummary{})
+		if err != nil {
+			return nil, err
+		}
+		if key == "default" {
+			r.fallback = tmpl
+		} else {
+			r.templates[key] = tmpl
+		}
+	}
+	return r, nil
+}
+
+// Render サマリに対応するテンプレートで投稿用テキストを生成する
+// "event.action" -> "event" -> "default" の順にテンプレートを探す
+func (r *Renderer) Render(summary EventSummary) (string, error) {
+	tmpl, ok := r.templates[summary.Event+"."+summary.Action]
+	if !ok {
+		tmpl, ok = r.templates[summary.Event]
+	}
+	if !ok {
+		tmpl = r.fallback
+	}
+	summary = escapeSummary(summary)
+	summary.Comment = truncateBody(summary.Comment, r.limit, summary.URL)
+	buf := bytes.Buffer{}
+	err := tmpl.Execute(&buf, summary)
+	if err != nil {
+		return "", err
+	}
+	return truncate(slackTextLimit, buf.String()), nil
+}
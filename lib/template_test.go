@@ -0,0 +1,165 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestRendererDefault(t *testing.T) {
+	renderer, err := NewRenderer(Config{})
+	if err != nil {
+		t.Fatal("failed: NewRenderer", err)
+	}
+	summary := EventSummary{
+		Event:          "issues",
+		Action:         "opened",
+		Kind:           "issue",
+		RepositoryName: "repo",
+		Title:          "tit",
+		URL:            "url",
+		Actor:          Actor{Login: "user"},
+		Comment:        "comm",
+	}
+	result, err := renderer.Render(summary)
+	if err != nil {
+		t.Fatal("failed: Render", err)
+	}
+	if result != CreatePostText(summary, Config{}) {
+		t.Fatal("failed: default layout", result)
+	}
+}
+
+func TestRendererTemplates(t *testing.T) {
+	conf := Config{
+		Templates: map[string]string{
+			"default":                            `{{.Title}}`,
+			"pull_request_review_comment":        `{{link .URL .Title}} {{emoji "eyes"}}`,
+			"pull_request_review_comment.edited": `{{truncate 3 .Comment}}`,
+			"issues":                             `{{.Title}} {{.Actor.Login}} #{{.Number}} {{escape "<b>"}}`,
+		},
+	}
+	renderer, err := NewRenderer(conf)
+	if err != nil {
+		t.Fatal("failed: NewRenderer", err)
+	}
+	type fromTo struct {
+		from EventSummary
+		to   string
+	}
+	table := map[string]fromTo{
+		"event": fromTo{
+			from: EventSummary{Event: "pull_request_review_comment", Action: "created", Title: "tit", URL: "url"},
+			to:   "<url|tit> :eyes:",
+		},
+		"event.action": fromTo{
+			from: EventSummary{Event: "pull_request_review_comment", Action: "edited", Comment: "comment"},
+			to:   "com…",
+		},
+		"escape": fromTo{
+			from: EventSummary{Event: "issues", Action: "opened", Title: "a < b & c", Actor: Actor{Login: "user"}, Number: 3},
+			to:   "a &lt; b &amp; c user #3 &lt;b&gt;",
+		},
+		"default": fromTo{
+			from: EventSummary{Event: "release", Title: "v1.0.0"},
+			to:   "v1.0.0",
+		},
+	}
+	for key, fromTo := range table {
+		result, err := renderer.Render(fromTo.from)
+		if err != nil {
+			t.Fatal("failed: "+key, err)
+		}
+		if result != fromTo.to {
+			t.Fatal("failed: "+key, "expect: "+fromTo.to, "actual: "+result)
+		}
+	}
+}
+
+func TestRendererInvalidTemplate(t *testing.T) {
+	_, err := NewRenderer(Config{Templates: map[string]string{"issues": `{{.Title`}})
+	if err == nil {
+		t.Fatal("failed: syntax error")
+	}
+	_, err = NewRenderer(Config{Templates: map[string]string{"issues": `{{.Unknown}}`}})
+	if err == nil {
+		t.Fatal("failed: unknown field")
+	}
+}
+
+func TestRendererMarkdown(t *testing.T) {
+	renderer, _ := NewRenderer(Config{Templates: map[string]string{"default": `{{.Comment}}`}})
+	result1, _ := renderer.Render(EventSummary{Kind: "comment", Comment: "**bold** [docs](url)"})
+	if result1 != "*bold* <url|docs>" {
+		t.Fatal("failed: convert markdown", result1)
+	}
+	// パーサがmrkdwnで組み立てた本文は変換しない
+	result2, _ := renderer.Render(EventSummary{Kind: "milestone", Comment: "*Due:* none"})
+	if result2 != "*Due:* none" {
+		t.Fatal("failed: keep mrkdwn", result2)
+	}
+}
+
+func TestRendererEscape(t *testing.T) {
+	renderer, _ := NewRenderer(Config{Templates: map[string]string{"default": `{{.Title}}|{{.Comment}}`}})
+	summary := EventSummary{
+		Kind:    "comment",
+		Title:   "<!channel> title",
+		Comment: "@a <!here> & **bold**",
+	}
+	summary.ReplaceComment(map[string]Account{"@a": Account{ID: "@aa"}})
+	result1, _ := renderer.Render(summary)
+	if result1 != "&lt;!channel&gt; title|<@aa> &lt;!here&gt; &amp; *bold*" {
+		t.Fatal("failed: escape markdown body", result1)
+	}
+	summary.Kind = "workflow"
+	result2, _ := renderer.Render(summary)
+	if result2 != "&lt;!channel&gt; title|<@aa> &lt;!here&gt; &amp; **bold**" {
+		t.Fatal("failed: escape plain body", result2)
+	}
+	// 本文に直接書かれたメンション記法は埋め込んだものでなければエスケープする
+	summary = EventSummary{Kind: "comment", Comment: "<@aa>"}
+	result3, _ := renderer.Render(summary)
+	if result3 != "|&lt;@aa&gt;" {
+		t.Fatal("failed: escape typed mention", result3)
+	}
+	// ブランチ名なども記法として解釈されないようにする
+	renderer, _ = NewRenderer(Config{Templates: map[string]string{"default": `{{.HeadRef}}|{{.Actor.Email}}|{{.CommitID}}|{{.DiffHunk}}`}})
+	summary = EventSummary{HeadRef: "<!channel>", Actor: Actor{Email: "<!here>"}, CommitID: "<@U1>", DiffHunk: "a & b"}
+	result4, _ := renderer.Render(summary)
+	if result4 != "&lt;!channel&gt;|&lt;!here&gt;|&lt;@U1&gt;|a &amp; b" {
+		t.Fatal("failed: escape new fields", result4)
+	}
+}
+
+func TestRendererBodyLimit(t *testing.T) {
+	renderer, _ := NewRenderer(Config{
+		Templates: map[string]string{"default": `{{.Comment}}`},
+		BodyLimit: BodyLimit{Lines: 1},
+	})
+	result, _ := renderer.Render(EventSummary{Kind: "comment", URL: "https://example.com/?a=1&b=2", Comment: "- first\n- second"})
+	if result != "• first\n<https://example.com/?a=1&amp;b=2|…see more>" {
+		t.Fatal("failed: truncate body", result)
+	}
+}
+
+func TestRendererCodeContext(t *testing.T) {
+	summary := EventSummary{
+		Event:    "pull_request_review_comment",
+		Kind:     "comment",
+		Title:    "tit",
+		URL:      "url",
+		DiffHunk: "@@ -1,4 +1,4 @@\n a\n b\n-c\n+d",
+		Comment:  "first\nsecond",
+	}
+	// 行数の上限は本文だけに効き、差分で本文が切り詰められることはない
+	renderer, _ := NewRenderer(Config{BodyLimit: BodyLimit{Lines: 4}})
+	result, _ := renderer.Render(summary)
+	if result != "*[] tit*\nurl\n> "+describe(summary)+"\n```\n a\n b\n-c\n+d\n```\nfirst\nsecond" {
+		t.Fatal("failed: default layout", result)
+	}
+	// テンプレートで差分を表示しないこともできる
+	renderer, _ = NewRenderer(Config{Templates: map[string]string{"default": `{{.Comment}}`}})
+	result, _ = renderer.Render(summary)
+	if result != "first\nsecond" {
+		t.Fatal("failed: hide code context", result)
+	}
+}
@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"strings"
+)
+
+const (
+	// slackTextLimit Slackのメッセージ全体の上限文字数
+	slackTextLimit = 40000
+	// slackSectionLimit Slackのセクションブロックの上限文字数
+	// 本文は設定に関わらずこの範囲に収める
+	slackSectionLimit = 3000
+)
+
+// cutText 文字数以内で、なるべく行や単語の区切りで切り詰める
+// リンクやメンションの記法、エスケープした文字の途中では切らない
+func cutText(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	text = string(runes[:length])
+	if i := strings.LastIndex(text, "\n"); i > len(text)/2 {
+		text = text[:i]
+	} else if i := strings.LastIndexAny(text, " \t"); i > len(text)/2 {
+		text = text[:i]
+	}
+	if i := strings.LastIndex(text, "<"); i > strings.LastIndex(text, ">") {
+		text = text[:i]
+	}
+	if i := strings.LastIndex(text, "&"); i > strings.LastIndex(text, ";") {
+		text = text[:i]
+	}
+	return text
+}
+
+// closeCodeFence 閉じられていないコードブロックを閉じる
+func closeCodeFence(text string) string {
+	fences := 0
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fences++
+		}
+	}
+	if fences%2 == 1 {
+		return text + "\n```"
+	}
+	return text
+}
+
+// truncateBody 本文を上限に収まるように切り詰め、末尾に続きへのリンクを付ける
+func truncateBody(body string, limit BodyLimit, url string) string {
+	const moreText = "…see more"
+	more := moreText
+	if url != "" {
+		more = "<" + url + "|" + moreText + ">"
+	}
+	// コードブロックを閉じる記号と続きへのリンクの分を空けておく
+	// 設定の文字数は見た目の長さ、Slackの上限はリンクの記法を含めた長さで数える
+	suffix := len("\n```\n")
+	chars := slackSectionLimit - suffix - len([]rune(more))
+	if limit.Chars > 0 && limit.Chars-suffix-len([]rune(moreText)) < chars {
+		chars = limit.Chars - suffix - len([]rune(moreText))
+	}
+	if chars < 0 {
+		chars = 0
+	}
+
+	text := body
+	truncated := false
+	if lines := strings.Split(text, "\n"); limit.Lines > 0 && len(lines) > limit.Lines {
+		text = strings.Join(lines[:limit.Lines], "\n")
+		truncated = true
+	}
+	if len([]rune(text)) > chars {
+		text = cutText(text, chars)
+		truncated = true
+	}
+	if !truncated {
+		return body
+	}
+	text = closeCodeFence(strings.TrimRight(text, " \t\n"))
+	return text + "\n" + more
+}
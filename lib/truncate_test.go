@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncateBody(t *testing.T) {
+	type fromTo struct {
+		body  string
+		limit BodyLimit
+		to    string
+	}
+	table := map[string]fromTo{
+		"short": fromTo{
+			body:  "short body",
+			limit: BodyLimit{Chars: 100},
+			to:    "short body",
+		},
+		"lines": fromTo{
+			body:  "line1\nline2\nline3",
+			limit: BodyLimit{Lines: 2},
+			to:    "line1\nline2\n<url|…see more>",
+		},
+		"chars at line": fromTo{
+			body:  "first line is here\nsecond line is long enough",
+			limit: BodyLimit{Chars: 35},
+			to:    "first line is here\n<url|…see more>",
+		},
+		"chars at word": fromTo{
+			body:  "aaaa bbbb cccc dddd eeee ffff",
+			limit: BodyLimit{Chars: 30},
+			to:    "aaaa bbbb cccc\n<url|…see more>",
+		},
+		"code fence": fromTo{
+			body:  "text\n```\ncode1\ncode2\ncode3\n```",
+			limit: BodyLimit{Lines: 4},
+			to:    "text\n```\ncode1\ncode2\n```\n<url|…see more>",
+		},
+		"link": fromTo{
+			body:  "see the docs <https://example.com/docs|docs>",
+			limit: BodyLimit{Chars: 40},
+			to:    "see the docs\n<url|…see more>",
+		},
+		"entity": fromTo{
+			body:  "aaaaaaaaaaaaaaaaa&amp;&amp;",
+			limit: BodyLimit{Chars: 37},
+			to:    "aaaaaaaaaaaaaaaaa&amp;\n<url|…see more>",
+		},
+	}
+	for key, fromTo := range table {
+		result := truncateBody(fromTo.body, fromTo.limit, "url")
+		if result != fromTo.to {
+			t.Fatal("failed: "+key, "\nexpect: "+fromTo.to, "\nactual: "+result)
+		}
+	}
+}
+
+func TestTruncateBodySlackLimit(t *testing.T) {
+	body := strings.Repeat("word ", 1000)
+	result := truncateBody(body, BodyLimit{}, "url")
+	if len([]rune(result)) > slackSectionLimit {
+		t.Fatal("failed: over section limit", len([]rune(result)))
+	}
+	if !strings.HasSuffix(result, "\n<url|…see more>") {
+		t.Fatal("failed: see more link", result[len(result)-30:])
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	type fromTo struct {
+		length int
+		from   string
+		to     string
+	}
+	table := []fromTo{
+		{3, "comment", "com…"},
+		{10, "short", "short"},
+		{12, "see <url|link text>", "see …"},
+		{12, "hello <@U123456789>", "hello …"},
+	}
+	for _, fromTo := range table {
+		if result := truncate(fromTo.length, fromTo.from); result != fromTo.to {
+			t.Fatal("failed: "+fromTo.from, "expect: "+fromTo.to, "actual: "+result)
+		}
+	}
+}
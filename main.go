@@ -1,14 +1,36 @@
 package main
 
 import (
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/miyanokomiya/gosla2/lib"
 )
 
+// resolver SlackユーザーIDの解決 (キャッシュを共有するため起動時に生成する)
+var resolver *lib.SlackResolver
+
+// store スラッシュコマンドで登録したアカウント連携
+var store *lib.Store
+
+// slackStack Slackからのリクエスト用のミドルウェア
+// Slackはフォーム形式で送ってくるので Content-Type の検査は外す
+// 署名の検証は設定を読んでから SlackVerifier を追加する
+var slackStack = []rest.Middleware{
+	&rest.AccessLogApacheMiddleware{},
+	&rest.TimerMiddleware{},
+	&rest.RecorderMiddleware{},
+	&rest.PoweredByMiddleware{},
+	&rest.RecoverMiddleware{
+		EnableResponseStackTrace: true,
+	},
+	&rest.JsonIndentMiddleware{},
+}
+
 func main() {
 	// Heroku環境を考慮してポートを取得
 	port := os.Getenv("PORT")
@@ -17,17 +39,56 @@ func main() {
 	}
 	log.Println("use port: " + port)
 
+	// 設定のテンプレートやタイムゾーンが不正な場合は起動しない
+	conf := lib.Config{}
+	err := conf.ParseFile("./config.toml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = lib.NewRenderer(conf)
+	if err != nil {
+		log.Fatal("invalid template: ", err)
+	}
+	err = conf.Validate()
+	if err != nil {
+		log.Fatal("invalid config: ", err)
+	}
+	resolver = lib.NewSlackResolver(conf.SlackToken, conf.SlackCacheFile)
+	store, err = lib.NewStore(conf.StoreFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	api := rest.NewApi()
 	api.Use(rest.DefaultDevStack...)
 	router, err := rest.MakeRouter(
 		rest.Get("/", root),
+		rest.Get("/github/events", getGithubEvents),
 		rest.Post("/github/events", postGithubEvents),
 	)
 	if err != nil {
 		log.Fatal(err)
 	}
 	api.SetApp(router)
-	log.Fatal(http.ListenAndServe(":"+port, api.MakeHandler()))
+
+	slackAPI := rest.NewApi()
+	slackAPI.Use(slackStack...)
+	slackAPI.Use(&lib.SlackVerifier{Secret: conf.SlackSigningSecret})
+	slackRouter, err := rest.MakeRouter(
+		rest.Post("/slack/commands", postSlackCommand),
+		rest.Post("/slack/interactions", postSlackInteractions),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slackAPI.SetApp(slackRouter)
+
+	go flushHeld(time.Minute)
+
+	mux := http.NewServeMux()
+	mux.Handle("/slack/", slackAPI.MakeHandler())
+	mux.Handle("/", api.MakeHandler())
+	log.Fatal(http.ListenAndServe(":"+port, mux))
 }
 
 // 生存確認用ルート画面
@@ -44,6 +105,13 @@ func postGithubEvents(w rest.ResponseWriter, r *rest.Request) {
 		return
 	}
 
+	store.MergeAccounts(&conf)
+	renderer, err := lib.NewRenderer(conf)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	hc := lib.HookContext{}
 	err = hc.ParseHook(r, conf.Secret)
 	if err != nil {
@@ -51,20 +119,228 @@ func postGithubEvents(w rest.ResponseWriter, r *rest.Request) {
 		return
 	}
 
-	summary := lib.EventSummary{}
-	err = summary.ParseEventSummary(hc)
+	if hc.Event == "ping" {
+		unsupported, err := lib.ValidatePing(hc.Payload)
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteJson(map[string]interface{}{"res": "pong", "unsupported": unsupported})
+		return
+	}
+
+	summaries, err := lib.ParseEventSummaries(hc)
 	if err == lib.ErrUnhandledEvent || err == lib.ErrUnhandledAction {
 		rest.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if _, ok := err.(*lib.MissingFieldError); ok {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == lib.ErrIgnoredEvent {
+		w.WriteJson(`{"res": "ignored"}`)
+		return
+	}
 	if err != nil {
 		rest.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	accounts := lib.FindAccounts(summary.Comment, conf)
-	summary.ReplaceComment(accounts)
-	text := lib.CreatePostText(summary)
-	lib.PostToAccounts(text, accounts)
+	for _, summary := range summaries {
+		summary, err := store.RecordCI(summary, time.Now())
+		if err == lib.ErrIgnoredEvent {
+			continue
+		}
+		if err != nil {
+			log.Println("failed: save CI result", err)
+		}
+		summary, err = lib.AddCommitMessage(summary, conf)
+		if err != nil {
+			log.Println("failed: fetch commit message", err)
+		}
+		notify(summary, conf, renderer)
+	}
 	w.WriteJson(`{"res": "finished"}`)
 }
+
+// getGithubEvents 対応しているGithubイベントとアクションの一覧
+func getGithubEvents(w rest.ResponseWriter, r *rest.Request) {
+	w.WriteJson(lib.SupportedEvents())
+}
+
+// notify サマリを関係するアカウントとチャンネルへ送信する
+func notify(summary lib.EventSummary, conf lib.Config, renderer *lib.Renderer) {
+	accounts := lib.FindMentionedAccounts(summary, conf)
+	mergeAccounts(accounts, lib.FindRecipientAccounts(summary.Recipients, conf))
+	mergeAccounts(accounts, lib.FindOwners(summary, conf))
+	mergeAccounts(accounts, lib.FindLabelSubscribers(summary, conf))
+	resolver.ResolveAccounts(accounts, summary)
+	store.FilterMuted(accounts, summary)
+	summary.ReplaceComment(accounts)
+	text, err := renderer.Render(summary)
+	if err != nil {
+		log.Println("failed: render template: "+summary.Event, err)
+		return
+	}
+	err = store.Hold(accounts, text, time.Now(), conf.IsUrgent(summary))
+	if err != nil {
+		log.Println("failed: hold notification", err)
+	}
+	msg := lib.NewNotification(text, summary, conf)
+	lib.PostMessageToAccounts(msg, accounts, conf)
+	lib.PostMessageToAccounts(msg, lib.FindSubscribers(summary, conf), conf)
+	lib.PostMessageToAccounts(msg, lib.FindAdminChannel(summary, conf), conf)
+}
+
+// flushHeld スヌーズや静かな時間帯が明けたSlackユーザーへ溜めておいた通知をまとめて送る
+func flushHeld(interval time.Duration) {
+	for range time.Tick(interval) {
+		due, err := store.TakeDue(time.Now())
+		if err != nil {
+			log.Println("failed: take held notifications", err)
+		}
+		if len(due) == 0 {
+			continue
+		}
+		conf := lib.Config{}
+		err = conf.ParseFile("./config.toml")
+		if err != nil {
+			log.Println("failed: flush held notifications", err)
+			continue
+		}
+		for _, held := range due {
+			err := lib.PostBundle(held, conf)
+			if err == nil {
+				continue
+			}
+			log.Println("failed: send held notifications: "+held.Account.ID, err)
+			// 送信先がない場合は送り直しても届かないので捨てる
+			if err == lib.ErrNoDestination {
+				continue
+			}
+			if err := store.Requeue(held); err != nil {
+				log.Println("failed: requeue held notifications", err)
+			}
+		}
+	}
+}
+
+// postSlackCommand /gosla スラッシュコマンド
+func postSlackCommand(w rest.ResponseWriter, r *rest.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	cmd, err := lib.ParseSlashCommand(body)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	conf := lib.Config{}
+	err = conf.ParseFile("./config.toml")
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	text := store.RunCommand(cmd, conf, lib.NewLinkVerifier(conf, resolver))
+	w.WriteJson(map[string]string{"response_type": "ephemeral", "text": text})
+}
+
+// postSlackInteractions 通知のボタン操作と、Githubへ返信するショートカット・モーダル
+func postSlackInteractions(w rest.ResponseWriter, r *rest.Request) {
+	conf := lib.Config{}
+	err := conf.ParseFile("./config.toml")
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	in, err := lib.ParseInteraction(body)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch {
+	case in.Type == "block_actions":
+		runActions(in)
+	case in.Type == "message_action" && in.CallbackID == lib.ReplyCallbackID:
+		openReplyModal(in, conf)
+	case in.Type == "view_submission" && in.View.CallbackID == lib.ReplyCallbackID:
+		err := postReply(in, conf)
+		if err != nil {
+			log.Println("failed: reply to Github", err)
+			w.WriteJson(lib.ReplyErrors(err))
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// runActions 押されたボタンの操作を記録し、元のメッセージのボタンを結果の表示に置き換える
+func runActions(in lib.Interaction) {
+	for _, action := range in.Actions {
+		text, err := store.RunAction(in.User.ID, action, time.Now())
+		if err != nil {
+			log.Println("failed: run action: "+action.ActionID, err)
+			text = "failed: " + err.Error()
+		}
+		err = lib.RespondToInteraction(in.ResponseURL, lib.NewConfirmation(in, text))
+		if err != nil {
+			log.Println("failed: respond to interaction", err)
+		}
+	}
+}
+
+// openReplyModal ショートカットを使ったメッセージの返信先を読み取り、返信モーダルを開く
+func openReplyModal(in lib.Interaction, conf lib.Config) {
+	source, ok := in.ReplySource()
+	text := "This message cannot be replied to on GitHub"
+	if _, linked := store.GithubLogin(in.User.ID, conf); !linked {
+		ok = false
+		text = "Link your GitHub account with `/gosla link` to reply on GitHub"
+	}
+	if !ok {
+		msg := lib.Message{Text: text, ResponseType: "ephemeral"}
+		err := lib.RespondToInteraction(in.ResponseURL, msg)
+		if err != nil {
+			log.Println("failed: respond to interaction", err)
+		}
+		return
+	}
+	err := lib.OpenReplyModal(conf.SlackToken, in.TriggerID, source)
+	if err != nil {
+		log.Println("failed: open reply modal", err)
+	}
+}
+
+// postReply 返信モーダルに入力された本文をGithubへ投稿する
+// 共有の認証情報で投稿するので、設定か連携でGithubアカウントが分かるユーザーに限り、誰の返信か本文に残す
+func postReply(in lib.Interaction, conf lib.Config) error {
+	source, err := in.SubmittedReplySource()
+	if err != nil {
+		return err
+	}
+	login, ok := store.GithubLogin(in.User.ID, conf)
+	if !ok {
+		return lib.ErrNotLinked
+	}
+	return lib.PostReply(source, lib.ReplyBody(in.ReplyText(), login), conf)
+}
+
+// mergeAccounts アカウント情報一覧をまとめる
+func mergeAccounts(dst map[string]lib.Account, src map[string]lib.Account) {
+	for key, account := range src {
+		dst[key] = account
+	}
+}